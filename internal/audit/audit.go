@@ -0,0 +1,185 @@
+// Package audit keeps an append-only log of destructive and mutating
+// actions, one JSON object per line, separate from the diagnostic log.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionDelete = "delete"
+	ActionEdit   = "edit"
+	ActionSend   = "send"
+	ActionUpload = "upload"
+	ActionPin    = "pin"
+	ActionUnpin  = "unpin"
+	ActionLogout = "logout"
+
+	ActionSessionExport = "session_export"
+	ActionSessionImport = "session_import"
+)
+
+// Sources an action can come from.
+const (
+	SourceAPI = "api"
+	SourceCLI = "cli"
+)
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	Account string    `json:"account"`
+	Source  string    `json:"source"`
+	// ClientIP is the address of the HTTP client, empty for the CLI.
+	ClientIP   string `json:"client_ip,omitempty"`
+	MessageIDs []int  `json:"message_ids,omitempty"`
+	// Result is "ok" or "error", with the error message in Error.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Log appends entries to the audit file. A nil *Log is valid and records
+// nothing, for when auditing is disabled.
+type Log struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open opens (or creates) the audit log at path for appending.
+func Open(path string) (*Log, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &Log{path: path, f: f}, nil
+}
+
+// Enabled reports whether entries are recorded.
+func (l *Log) Enabled() bool {
+	return l != nil
+}
+
+// Record appends an entry, filling in the time and the result from err.
+// Failing to write is logged but does not fail the action being audited.
+func (l *Log) Record(e Entry, err error) {
+	if l == nil {
+		return
+	}
+
+	e.Time = time.Now().UTC()
+	e.Result = "ok"
+	if err != nil {
+		e.Result = "error"
+		e.Error = err.Error()
+	}
+
+	line, mErr := json.Marshal(e)
+	if mErr != nil {
+		slog.Error("Failed to encode audit entry", "err", mErr)
+		return
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// One write per entry, so concurrent writers (the server and a CLI
+	// command) cannot interleave lines with O_APPEND
+	if _, wErr := l.f.Write(line); wErr != nil {
+		slog.Error("Failed to write audit entry", "path", l.path, "err", wErr)
+		return
+	}
+	if sErr := l.f.Sync(); sErr != nil {
+		slog.Error("Failed to sync audit log", "path", l.path, "err", sErr)
+	}
+}
+
+// MaxRead is the most entries Read returns at once.
+const MaxRead = 1000
+
+// Filter selects entries in Read. Zero fields match everything.
+type Filter struct {
+	Account string
+	Action  string
+}
+
+func (f Filter) match(e Entry) bool {
+	return (f.Account == "" || e.Account == f.Account) &&
+		(f.Action == "" || e.Action == f.Action)
+}
+
+// Read returns up to limit of the most recent entries matching filter,
+// newest first. A limit of 0 or above MaxRead reads MaxRead entries.
+func (l *Log) Read(filter Filter, limit int) ([]Entry, error) {
+	if l == nil {
+		return nil, errors.New("audit log is disabled")
+	}
+	if limit <= 0 || limit > MaxRead {
+		limit = MaxRead
+	}
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A torn last line after a crash should not hide everything else
+			continue
+		}
+		if !filter.match(e) {
+			continue
+		}
+		entries = append(entries, e)
+		// Only the tail is returned, no need to hold on to the rest
+		if len(entries) > 2*limit {
+			entries = append(entries[:0], entries[len(entries)-limit:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// Close closes the audit file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+
+	l.Record(Entry{Action: ActionLogout, Account: "c", Source: SourceAPI}, errors.New("not connected"))
+
+	// Entry i is for message i: account "a" for even i, "b" for odd ones,
+	// and a deletion for every third
+	for i := range 1200 {
+		e := Entry{Action: ActionEdit, Account: "a", Source: SourceCLI, MessageIDs: []int{i}}
+		if i%2 == 1 {
+			e.Account = "b"
+		}
+		if i%3 == 0 {
+			e.Action = ActionDelete
+		}
+		l.Record(e, nil)
+	}
+
+	// A last line torn by a crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2026-10-18T12:00:00Z","action":"del`)
+	f.Close()
+
+	tests := []struct {
+		name   string
+		filter Filter
+		limit  int
+		// first and last are the message IDs of the first and last entry
+		// returned, count how many there are
+		count, first, last int
+	}{
+		{"tail", Filter{}, 5, 5, 1199, 1195},
+		{"account", Filter{Account: "b"}, 3, 3, 1199, 1195},
+		{"action", Filter{Action: ActionDelete}, 2, 2, 1197, 1194},
+		{"account and action", Filter{Account: "a", Action: ActionDelete}, 2, 2, 1194, 1188},
+		{"fewer than the limit", Filter{Account: "a", Action: ActionDelete}, 500, 200, 1194, 0},
+		{"no limit", Filter{}, 0, MaxRead, 1199, 200},
+		{"limit above the cap", Filter{}, 5000, MaxRead, 1199, 200},
+		{"no match", Filter{Account: "nobody"}, 10, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := l.Read(tt.filter, tt.limit)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if len(entries) != tt.count {
+				t.Fatalf("got %d entries, want %d", len(entries), tt.count)
+			}
+			if tt.count == 0 {
+				return
+			}
+			if first, last := entries[0].MessageIDs[0], entries[len(entries)-1].MessageIDs[0]; first != tt.first || last != tt.last {
+				t.Errorf("got messages %d to %d, want %d to %d", first, last, tt.first, tt.last)
+			}
+			for _, e := range entries {
+				if !tt.filter.match(e) {
+					t.Errorf("entry %+v does not match the filter", e)
+				}
+			}
+		})
+	}
+
+	// The failed action is recorded with its error
+	entries, err := l.Read(Filter{Account: "c"}, 1)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("got %v, %v", entries, err)
+	}
+	if e := entries[0]; e.Result != "error" || e.Error != "not connected" || e.Time.IsZero() || e.ClientIP != "" {
+		t.Errorf("got %+v", e)
+	}
+}
+
+func TestDisabledLog(t *testing.T) {
+	var l *Log
+	if l.Enabled() {
+		t.Error("nil log reports being enabled")
+	}
+	l.Record(Entry{Action: ActionDelete}, nil) // Must not panic
+	if _, err := l.Read(Filter{}, 10); err == nil {
+		t.Error("reading a disabled log did not fail")
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+}
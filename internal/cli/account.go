@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"telegram-manager/internal/audit"
+	"telegram-manager/internal/tg"
+)
+
+func init() {
+	register(&command{
+		name:        "login",
+		usage:       "",
+		summary:     "Log in interactively and store the session.",
+		interactive: true,
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error {
+			return func(ctx context.Context, c *tg.Client, args []string) error {
+				// StartAndListen has already run the login flow if it was needed
+				u := c.CurrentUser()
+				fmt.Fprintf(stdout, "Account %s is logged in as %s %s (@%s)\n", c.Account, u.FirstName, u.LastName, u.Username)
+				return nil
+			}
+		},
+	})
+
+	register(&command{
+		name:    "logout",
+		usage:   "[-yes]",
+		summary: "Log out from Telegram and delete the session file.",
+		// Connects itself, so a session Telegram no longer accepts can
+		// still be removed
+		offline: true,
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error {
+			yes := fs.Bool("yes", false, "do not ask for confirmation")
+
+			return func(ctx context.Context, c *tg.Client, args []string) error {
+				if !*yes && !confirm(fmt.Sprintf("Log out account %s?", c.Account)) {
+					return fmt.Errorf("aborted")
+				}
+
+				err := c.StartAndListen(ctx, c.Logout)
+				if errors.Is(err, tg.ErrNotLoggedIn) {
+					// The auth key was revoked; Logout only removes the files
+					err = c.Logout(ctx)
+				}
+				record(c, audit.ActionLogout, nil, err)
+				if err != nil {
+					return err
+				}
+				slog.Info("Logged out via CLI", "account", c.Account)
+				fmt.Fprintf(stdout, "Logged out account %s\n", c.Account)
+				return nil
+			}
+		},
+	})
+}
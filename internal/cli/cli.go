@@ -0,0 +1,147 @@
+// Package cli implements the command-line subcommands used for headless
+// maintenance of Saved Messages, e.g. from cron.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"telegram-manager/internal/audit"
+	"telegram-manager/internal/tg"
+)
+
+// command is a single subcommand. setup registers the command's flags and
+// returns the function that runs it once the client is connected.
+type command struct {
+	name    string
+	usage   string
+	summary string
+	// interactive commands may prompt for the phone number and login code.
+	interactive bool
+	// offline commands run without connecting first: they only work on
+	// local files or connect themselves.
+	offline bool
+	setup   func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error
+}
+
+var commands = map[string]*command{}
+
+func register(cmd *command) {
+	commands[cmd.name] = cmd
+}
+
+// stdout is where command output goes. Logs go to stderr, so JSON output
+// can be piped into other tools.
+var stdout io.Writer = os.Stdout
+
+// auditLog records the changes made by commands, like the server does for
+// the API. Nil when auditing is disabled.
+var auditLog *audit.Log
+
+// record adds a command's change to the audit log.
+func record(c *tg.Client, action string, ids []int, err error) {
+	auditLog.Record(audit.Entry{
+		Action:     action,
+		Account:    c.Account,
+		Source:     audit.SourceCLI,
+		MessageIDs: ids,
+	}, err)
+}
+
+// Run executes the subcommand in args[0] with its arguments. accounts are
+// the configured account names, the first one is used unless -account is given.
+// Changes are recorded in the audit trail, which may be nil.
+func Run(ctx context.Context, args []string, accounts []string, opts tg.Options, trail *audit.Log) error {
+	auditLog = trail
+
+	if len(args) == 0 || args[0] == "help" {
+		printUsage(os.Stderr)
+		return nil
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		printUsage(os.Stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: telegram-manager [flags] %s %s\n\n%s\n\n", cmd.name, cmd.usage, cmd.summary)
+		fs.PrintDefaults()
+	}
+	account := fs.String("account", accounts[0], "account to use")
+	run := cmd.setup(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	known := false
+	for _, name := range accounts {
+		if name == *account {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown account %q (configured: %s)", *account, strings.Join(accounts, ", "))
+	}
+
+	// Only the login command may ask for a code; everything else is meant
+	// to run unattended and should fail fast instead of hanging on stdin.
+	opts.NonInteractive = !cmd.interactive
+
+	client, err := tg.NewClient(*account, opts)
+	if err != nil {
+		return err
+	}
+
+	if cmd.offline {
+		return run(ctx, client, fs.Args())
+	}
+
+	err = client.StartAndListen(ctx, func(ctx context.Context) error {
+		return run(ctx, client, fs.Args())
+	})
+	if errors.Is(err, tg.ErrNotLoggedIn) {
+		return fmt.Errorf("account %q is not logged in, run the login command first", *account)
+	}
+	return err
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: telegram-manager [flags] [command] [command flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command (or with \"serve\") the web interface is started.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-15s %s\n", name, commands[name].summary)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"telegram-manager <command> -h\" for the flags of a command.")
+}
+
+// writeJSON prints v as indented JSON.
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"telegram-manager/internal/export"
+	"telegram-manager/internal/tg"
+)
+
+func init() {
+	register(&command{
+		name:    "export",
+		usage:   "[-type links|messages] [-format bookmarks|json|csv] [-takeout] [-media dir] [-o file]",
+		summary: "Export saved links or all messages.",
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error {
+			kind := fs.String("type", "links", "what to export: links or messages")
+			formatName := fs.String("format", "json", "links format: bookmarks, json or csv (messages are always JSON)")
+			output := fs.String("o", "", "output file (default: stdout)")
+			takeout := fs.Bool("takeout", false, "export messages in a takeout session, which Telegram rate limits less (must be allowed in another Telegram app)")
+			takeoutWait := fs.Duration("takeout-wait", tg.DefaultTakeoutWait, "how long to wait for the takeout session to be allowed")
+			mediaDir := fs.String("media", "", "also save the photos and documents of exported messages to this directory")
+
+			return func(ctx context.Context, c *tg.Client, args []string) error {
+				if *kind != "messages" && (*takeout || *mediaDir != "") {
+					return fmt.Errorf("-takeout and -media only apply to -type messages")
+				}
+
+				var write func(w io.Writer) error
+				switch *kind {
+				case "links":
+					format, err := export.ParseFormat(*formatName)
+					if err != nil {
+						return err
+					}
+					links, err := c.GetLinks(ctx)
+					if err != nil {
+						return err
+					}
+					write = func(w io.Writer) error { return export.WriteLinks(w, format, links) }
+				case "messages":
+					if *formatName != "json" {
+						return fmt.Errorf("messages can only be exported as json")
+					}
+					opts := tg.ExportOptions{Takeout: *takeout, TakeoutWait: *takeoutWait}
+					if *mediaDir != "" {
+						if err := os.MkdirAll(*mediaDir, 0755); err != nil {
+							return fmt.Errorf("failed to create media directory: %w", err)
+						}
+						opts.Media = func(msgID int, contentType string, download func(w io.Writer) error) error {
+							name := filepath.Join(*mediaDir, strconv.Itoa(msgID)+mediaExtension(contentType))
+							f, err := os.Create(name)
+							if err != nil {
+								return fmt.Errorf("failed to save media: %w", err)
+							}
+							if err := download(f); err != nil {
+								// Leave no partial files behind
+								f.Close()
+								os.Remove(name)
+								return err
+							}
+							if err := f.Close(); err != nil {
+								return fmt.Errorf("failed to save media: %w", err)
+							}
+							return nil
+						}
+					}
+
+					var messages []tg.SavedMessage
+					var err error
+					if opts.Takeout || opts.Media != nil {
+						messages, err = c.ExportMessages(ctx, opts)
+					} else {
+						messages, err = allMessages(ctx, c)
+					}
+					if err != nil {
+						return err
+					}
+					write = func(w io.Writer) error {
+						enc := json.NewEncoder(w)
+						enc.SetIndent("", "  ")
+						return enc.Encode(messages)
+					}
+				default:
+					return fmt.Errorf("unknown export type %q", *kind)
+				}
+
+				if *output == "" {
+					return write(stdout)
+				}
+
+				f, err := os.Create(*output)
+				if err != nil {
+					return fmt.Errorf("failed to create output file: %w", err)
+				}
+				if err := write(f); err != nil {
+					f.Close()
+					return err
+				}
+				if err := f.Close(); err != nil {
+					return err
+				}
+
+				slog.Info("Exported via CLI", "account", c.Account, "type", *kind, "output", *output)
+				return nil
+			}
+		},
+	})
+}
+
+// allMessages pages through the whole Saved Messages history, newest first.
+func allMessages(ctx context.Context, c *tg.Client) ([]tg.SavedMessage, error) {
+	var all []tg.SavedMessage
+	cursor := tg.Cursor{Order: tg.OrderDesc}
+	for {
+		// A huge limit is clamped to the configured maximum page size
+		page, err := c.GetMessagePage(ctx, cursor, 1<<20)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page.Messages...)
+
+		if page.NextCursor == "" {
+			return all, nil
+		}
+		if cursor, err = tg.ParseCursor(page.NextCursor); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// mediaExtension returns the file extension for a media content type.
+func mediaExtension(contentType string) string {
+	if contentType == "image/jpeg" {
+		// The mime table lists the rarer .jfif and .jpe first
+		return ".jpg"
+	}
+	if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"telegram-manager/internal/audit"
+	"telegram-manager/internal/tg"
+)
+
+// previewLength is how much of a message's text the table output shows.
+const previewLength = 60
+
+func init() {
+	register(&command{
+		name:    "list",
+		usage:   "[-limit n] [-order desc|asc] [-cursor c] [-json]",
+		summary: "List Saved Messages a page at a time.",
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error {
+			limit := fs.Int("limit", 0, "number of messages (default: the configured page size)")
+			order := fs.String("order", "desc", "desc (newest first) or asc (oldest first)")
+			cursorStr := fs.String("cursor", "", "continue from the cursor printed by a previous call")
+			asJSON := fs.Bool("json", false, "print JSON instead of a table")
+
+			return func(ctx context.Context, c *tg.Client, args []string) error {
+				var cursor tg.Cursor
+				var err error
+				if *cursorStr != "" {
+					cursor, err = tg.ParseCursor(*cursorStr)
+				} else {
+					cursor.Order, err = tg.ParseOrder(*order)
+				}
+				if err != nil {
+					return err
+				}
+
+				page, err := c.GetMessagePage(ctx, cursor, *limit)
+				if err != nil {
+					return err
+				}
+				if *asJSON {
+					return writeJSON(page)
+				}
+				if err := printMessages(page.Messages); err != nil {
+					return err
+				}
+				if page.NextCursor != "" {
+					// stderr, so the table can be piped without it
+					fmt.Fprintf(os.Stderr, "\nNext page: -cursor %s\n", page.NextCursor)
+				}
+				return nil
+			}
+		},
+	})
+
+	register(&command{
+		name:    "search",
+		usage:   "[-limit n] [-json] query...",
+		summary: "Search Saved Messages for text.",
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error {
+			limit := fs.Int("limit", 0, "maximum number of results (default: the configured page size)")
+			asJSON := fs.Bool("json", false, "print JSON instead of a table")
+
+			return func(ctx context.Context, c *tg.Client, args []string) error {
+				messages, total, err := c.SearchMessages(ctx, strings.Join(args, " "), *limit)
+				if err != nil {
+					return err
+				}
+				if *asJSON {
+					return writeJSON(map[string]interface{}{"messages": messages, "count": total})
+				}
+				return printMessages(messages)
+			}
+		},
+	})
+
+	register(&command{
+		name:    "delete",
+		usage:   "[-yes] id...",
+		summary: "Delete messages by ID.",
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error {
+			yes := fs.Bool("yes", false, "do not ask for confirmation")
+
+			return func(ctx context.Context, c *tg.Client, args []string) error {
+				ids, err := parseIDs(args)
+				if err != nil {
+					return err
+				}
+
+				if !*yes && !confirm(fmt.Sprintf("Delete %d message(s) from %s?", len(ids), c.Account)) {
+					return fmt.Errorf("aborted")
+				}
+
+				err = c.DeleteMessages(ctx, ids)
+				record(c, audit.ActionDelete, ids, err)
+				if err != nil {
+					return err
+				}
+				slog.Info("Deleted messages via CLI", "account", c.Account, "ids", ids)
+				fmt.Fprintf(stdout, "Deleted %d message(s)\n", len(ids))
+				return nil
+			}
+		},
+	})
+}
+
+// printMessages prints messages as a table with a one-line text preview.
+func printMessages(messages []tg.SavedMessage) error {
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDATE\tTYPE\tPINNED\tTEXT")
+	for _, m := range messages {
+		kind := m.MediaType
+		if kind == "" {
+			kind = "text"
+		}
+		if len(m.IDs) > 1 {
+			kind = fmt.Sprintf("album(%d)", len(m.IDs))
+		}
+
+		pinned := ""
+		if m.Pinned {
+			pinned = "yes"
+		}
+
+		date := time.Unix(int64(m.Date), 0).Format("2006-01-02 15:04")
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", m.ID, date, kind, pinned, preview(m.Message))
+	}
+	return tw.Flush()
+}
+
+// preview shortens text to a single line of at most previewLength characters.
+func preview(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) > previewLength {
+		return string(runes[:previewLength-1]) + "…"
+	}
+	return text
+}
+
+func parseIDs(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no message IDs given")
+	}
+
+	ids := make([]int, 0, len(args))
+	for _, arg := range args {
+		// Accept both "1 2 3" and "1,2,3"
+		for _, part := range strings.Split(arg, ",") {
+			if part == "" {
+				continue
+			}
+			id, err := strconv.Atoi(part)
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid message ID %q", part)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
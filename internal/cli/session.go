@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"golang.org/x/term"
+
+	"telegram-manager/internal/audit"
+	"telegram-manager/internal/tg"
+)
+
+// transferPassphraseEnv holds the passphrase of exported sessions, for
+// scripts. Without it the passphrase is asked for on the terminal.
+const transferPassphraseEnv = "TG_TRANSFER_PASSPHRASE"
+
+func init() {
+	register(&command{
+		name:    "session-export",
+		usage:   "[-o file]",
+		summary: "Print the session as an encrypted string to move it to another install.",
+		offline: true,
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error {
+			output := fs.String("o", "", "write the string to this file instead of stdout")
+
+			return func(ctx context.Context, c *tg.Client, args []string) error {
+				passphrase, err := transferPassphrase(true, false)
+				if err != nil {
+					return err
+				}
+
+				exported, err := c.ExportSession(ctx, passphrase)
+				record(c, audit.ActionSessionExport, nil, err)
+				if errors.Is(err, tg.ErrNoSession) {
+					return fmt.Errorf("account %q is not logged in, run the login command first", c.Account)
+				}
+				if err != nil {
+					return err
+				}
+
+				if *output != "" {
+					if err := os.WriteFile(*output, []byte(exported+"\n"), 0600); err != nil {
+						return fmt.Errorf("failed to write session: %w", err)
+					}
+					slog.Info("Exported session", "account", c.Account, "file", *output)
+					return nil
+				}
+				fmt.Fprintln(stdout, exported)
+				return nil
+			}
+		},
+	})
+
+	register(&command{
+		name:    "session-import",
+		usage:   "[-replace] [string | -i file]",
+		summary: "Store a session exported by session-export on another install.",
+		offline: true,
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error {
+			input := fs.String("i", "", "read the string from this file (- for stdin)")
+			replace := fs.Bool("replace", false, "overwrite an existing session of the account")
+
+			return func(ctx context.Context, c *tg.Client, args []string) error {
+				var exported string
+				switch {
+				case *input == "-":
+					data, err := io.ReadAll(os.Stdin)
+					if err != nil {
+						return fmt.Errorf("failed to read session: %w", err)
+					}
+					exported = string(data)
+				case *input != "":
+					data, err := os.ReadFile(*input)
+					if err != nil {
+						return fmt.Errorf("failed to read session: %w", err)
+					}
+					exported = string(data)
+				case len(args) == 1:
+					exported = args[0]
+				default:
+					return errors.New("give the exported session as the argument or with -i")
+				}
+
+				passphrase, err := transferPassphrase(false, *input == "-")
+				if err != nil {
+					return err
+				}
+
+				err = c.ImportSession(ctx, exported, passphrase, *replace)
+				record(c, audit.ActionSessionImport, nil, err)
+				if errors.Is(err, tg.ErrSessionExists) {
+					return fmt.Errorf("account %q already has a session, use -replace to overwrite it", c.Account)
+				}
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(stdout, "Imported session for account %s\n", c.Account)
+				return nil
+			}
+		},
+	})
+}
+
+// transferPassphrase returns the passphrase of exported sessions from the
+// environment, or asks for it on the terminal with echo off (twice with
+// confirm, when it is being set). stdinTaken is set when stdin carries the
+// session, so it cannot carry the passphrase as well.
+func transferPassphrase(confirm, stdinTaken bool) (string, error) {
+	if p := os.Getenv(transferPassphraseEnv); p != "" {
+		return p, nil
+	}
+
+	// Ask on the controlling terminal, which works even with stdin and
+	// stdout redirected. Without one (e.g. on Windows) fall back to stdin
+	// if that is a terminal.
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		if stdinTaken || !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", fmt.Errorf("no terminal to ask for the passphrase, set %s", transferPassphraseEnv)
+		}
+		tty = os.Stdin
+	} else {
+		defer tty.Close()
+	}
+
+	ask := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		pass, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase (or set %s): %w", transferPassphraseEnv, err)
+		}
+		return string(pass), nil
+	}
+
+	passphrase, err := ask("Passphrase for the exported session: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := ask("Repeat the passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
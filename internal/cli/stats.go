@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"telegram-manager/internal/tg"
+)
+
+func init() {
+	register(&command{
+		name:    "stats",
+		usage:   "[-top n] [-json]",
+		summary: "Show storage usage of Saved Messages.",
+		setup: func(fs *flag.FlagSet) func(ctx context.Context, c *tg.Client, args []string) error {
+			top := fs.Int("top", 10, "number of largest files to list")
+			asJSON := fs.Bool("json", false, "print JSON instead of tables")
+
+			return func(ctx context.Context, c *tg.Client, args []string) error {
+				stats, err := c.GetStats(ctx, *top)
+				if err != nil {
+					return err
+				}
+				if *asJSON {
+					return writeJSON(stats)
+				}
+				return printStats(stats)
+			}
+		},
+	})
+}
+
+func printStats(stats *tg.Stats) error {
+	fmt.Fprintf(stdout, "Messages: %d\nFiles:    %d\nSize:     %s\n", stats.TotalMessages, stats.TotalFiles, formatBytes(stats.TotalBytes))
+
+	sections := []struct {
+		title   string
+		buckets []tg.StatBucket
+	}{
+		{"BY MEDIA TYPE", stats.ByMediaType},
+		{"BY EXTENSION", stats.ByExtension},
+		{"BY FORWARD SOURCE", stats.ByForwardSource},
+		{"BY MONTH", stats.ByMonth},
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, section := range sections {
+		if len(section.buckets) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\n%s\tCOUNT\tSIZE\n", section.title)
+		for _, b := range section.buckets {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", b.Key, b.Count, formatBytes(b.Bytes))
+		}
+	}
+
+	if len(stats.LargestFiles) > 0 {
+		fmt.Fprintln(tw, "\nLARGEST FILES\tID\tDATE\tTYPE\tSIZE")
+		for _, f := range stats.LargestFiles {
+			name := f.Name
+			if name == "" {
+				name = "-"
+			}
+			date := time.Unix(int64(f.Date), 0).Format(time.DateOnly)
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\n", name, f.MessageID, date, f.MediaType, formatBytes(f.Size))
+		}
+	}
+
+	return tw.Flush()
+}
+
+// formatBytes renders a size with a binary unit, like the web UI does.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
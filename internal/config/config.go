@@ -0,0 +1,391 @@
+// Package config loads the application configuration from an optional
+// YAML or TOML file, environment variables and command-line flags, in
+// increasing order of precedence.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"telegram-manager/internal/proxy"
+)
+
+// Config is the complete application configuration.
+type Config struct {
+	Telegram TelegramConfig `yaml:"telegram" toml:"telegram"`
+	Session  SessionConfig  `yaml:"session" toml:"session"`
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Messages MessagesConfig `yaml:"messages" toml:"messages"`
+	Cache    CacheConfig    `yaml:"cache" toml:"cache"`
+	Log      LogConfig      `yaml:"log" toml:"log"`
+}
+
+type TelegramConfig struct {
+	AppID   int    `yaml:"app_id" toml:"app_id"`
+	AppHash string `yaml:"app_hash" toml:"app_hash"`
+	// Accounts are the names of the accounts to log into. Each one keeps its own session.
+	Accounts []string `yaml:"accounts" toml:"accounts"`
+	// MediaWorkers is how many media downloads run at once per account.
+	MediaWorkers int `yaml:"media_workers" toml:"media_workers"`
+	// Proxy is a socks5://, http:// or mtproxy:// URL (or a tg://proxy
+	// link) to connect to Telegram through. Empty connects directly.
+	Proxy string `yaml:"proxy" toml:"proxy"`
+}
+
+type SessionConfig struct {
+	// Dir holds the session files.
+	Dir string `yaml:"dir" toml:"dir"`
+	// Passphrase or Keyfile enable encryption of session files at rest.
+	Passphrase string `yaml:"passphrase" toml:"passphrase"`
+	Keyfile    string `yaml:"keyfile" toml:"keyfile"`
+}
+
+type ServerConfig struct {
+	// Host is the interface to listen on. Empty means all interfaces.
+	Host      string    `yaml:"host" toml:"host"`
+	Port      int       `yaml:"port" toml:"port"`
+	Password  string    `yaml:"password" toml:"password"`
+	StaticDir string    `yaml:"static_dir" toml:"static_dir"`
+	TLS       TLSConfig `yaml:"tls" toml:"tls"`
+}
+
+type TLSConfig struct {
+	CertFile   string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile    string `yaml:"key_file" toml:"key_file"`
+	SelfSigned bool   `yaml:"self_signed" toml:"self_signed"`
+	// Dir holds the generated self-signed certificate.
+	Dir string `yaml:"dir" toml:"dir"`
+	// RedirectPort, if non-zero, serves plain HTTP redirects to HTTPS.
+	RedirectPort int `yaml:"redirect_port" toml:"redirect_port"`
+}
+
+type MessagesConfig struct {
+	// DefaultPageSize is used when a request does not specify a limit.
+	DefaultPageSize int `yaml:"default_page_size" toml:"default_page_size"`
+	// MaxPageSize caps the limit of a single request. Telegram allows at most 100.
+	MaxPageSize int `yaml:"max_page_size" toml:"max_page_size"`
+}
+
+type CacheConfig struct {
+	// MediaMaxBytes bounds the in-memory media cache. 0 disables it.
+	MediaMaxBytes int64 `yaml:"media_max_bytes" toml:"media_max_bytes"`
+	// MediaTTL is how long downloaded media stays cached, in memory and in browsers.
+	MediaTTL time.Duration `yaml:"media_ttl" toml:"media_ttl"`
+}
+
+type LogConfig struct {
+	// Level is one of debug, info, warn, error.
+	Level string `yaml:"level" toml:"level"`
+	// Format is text (human-readable key=value) or json.
+	Format string `yaml:"format" toml:"format"`
+	// AuditFile receives a JSON line per mutating action. Empty disables it.
+	AuditFile string `yaml:"audit_file" toml:"audit_file"`
+}
+
+// Default returns the configuration used when nothing else is set.
+func Default() *Config {
+	return &Config{
+		Telegram: TelegramConfig{
+			Accounts:     []string{"default"},
+			MediaWorkers: 4,
+		},
+		Session: SessionConfig{
+			Dir: "session",
+		},
+		Server: ServerConfig{
+			// Only accept local connections unless configured otherwise
+			Host: "127.0.0.1",
+			Port: 8080,
+			TLS: TLSConfig{
+				Dir: "tls",
+			},
+		},
+		Messages: MessagesConfig{
+			DefaultPageSize: 20,
+			MaxPageSize:     100,
+		},
+		Cache: CacheConfig{
+			MediaMaxBytes: 64 << 20,
+			MediaTTL:      10 * time.Minute,
+		},
+		Log: LogConfig{
+			Level:     "info",
+			Format:    "text",
+			AuditFile: "audit.jsonl",
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the config file (from the
+// -config flag or the CONFIG environment variable), environment variables
+// and command-line flags, then validates it. Arguments after the flags
+// (a subcommand and its own arguments) are returned unparsed.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("telegram-manager", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("CONFIG"), "path to a YAML or TOML config file")
+	flags := registerFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, nil, err
+	}
+
+	// Only flags given on the command line override file and environment
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		if apply, ok := flags[f.Name]; ok && flagErr == nil {
+			flagErr = apply(cfg)
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// loadFile merges a YAML (.yaml, .yml) or TOML (.toml) file into cfg.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unsupported config file %q: use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyEnv overrides settings with the environment variables that are set.
+func (c *Config) applyEnv() error {
+	var err error
+	setInt := func(name string, dst *int) {
+		if v, ok := os.LookupEnv(name); ok && v != "" && err == nil {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				err = fmt.Errorf("invalid %s: %w", name, err)
+			}
+		}
+	}
+	setString := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			*dst = v
+		}
+	}
+
+	setInt("TG_APP_ID", &c.Telegram.AppID)
+	setString("TG_APP_HASH", &c.Telegram.AppHash)
+	if v := os.Getenv("TG_ACCOUNTS"); v != "" {
+		c.Telegram.Accounts = splitList(v)
+	}
+
+	setInt("TG_MEDIA_WORKERS", &c.Telegram.MediaWorkers)
+	setString("TG_PROXY", &c.Telegram.Proxy)
+	setString("SESSION_DIR", &c.Session.Dir)
+	setString("TG_SESSION_PASSPHRASE", &c.Session.Passphrase)
+	setString("TG_SESSION_KEYFILE", &c.Session.Keyfile)
+
+	// An empty HOST is meaningful: listen on all interfaces
+	if v, ok := os.LookupEnv("HOST"); ok {
+		c.Server.Host = v
+	}
+	setInt("PORT", &c.Server.Port)
+	setString("AUTH_PASSWORD", &c.Server.Password)
+	setString("STATIC_DIR", &c.Server.StaticDir)
+	setString("TLS_CERT", &c.Server.TLS.CertFile)
+	setString("TLS_KEY", &c.Server.TLS.KeyFile)
+	if v := os.Getenv("TLS_SELF_SIGNED"); v != "" {
+		c.Server.TLS.SelfSigned = v == "1" || v == "true"
+	}
+	setString("TLS_DIR", &c.Server.TLS.Dir)
+	setInt("HTTP_REDIRECT_PORT", &c.Server.TLS.RedirectPort)
+
+	setString("LOG_LEVEL", &c.Log.Level)
+	setString("LOG_FORMAT", &c.Log.Format)
+	// An empty AUDIT_LOG is meaningful: disable the audit log
+	if v, ok := os.LookupEnv("AUDIT_LOG"); ok {
+		c.Log.AuditFile = v
+	}
+
+	return err
+}
+
+// registerFlags defines the command-line flags on fs and returns, for each
+// flag name, a function applying its parsed value to a config.
+func registerFlags(fs *flag.FlagSet) map[string]func(*Config) error {
+	appID := fs.Int("app-id", 0, "Telegram API app ID")
+	appHash := fs.String("app-hash", "", "Telegram API app hash")
+	accounts := fs.String("accounts", "", "comma-separated account names")
+	mediaWorkers := fs.Int("media-workers", 0, "concurrent media downloads per account")
+	tgProxy := fs.String("proxy", "", "connect to Telegram through this proxy: socks5://, http://, mtproxy:// or a tg://proxy link")
+	sessionDir := fs.String("session-dir", "", "directory for session files")
+	host := fs.String("host", "", "interface to listen on (empty for all)")
+	port := fs.Int("port", 0, "HTTP port")
+	staticDir := fs.String("static-dir", "", "serve the web UI from this directory instead of the embedded copy (frontend development)")
+	defaultPageSize := fs.Int("page-size", 0, "default number of messages per page")
+	maxPageSize := fs.Int("max-page-size", 0, "maximum number of messages per page")
+	mediaCache := fs.Int64("media-cache-bytes", 0, "size of the in-memory media cache in bytes (0 disables it)")
+	mediaTTL := fs.Duration("media-cache-ttl", 0, "how long media stays cached")
+	logLevel := fs.String("log-level", "", "log level: debug, info, warn or error")
+	logFormat := fs.String("log-format", "", "log format: text or json")
+	auditLog := fs.String("audit-log", "", "file recording deletions and other changes as JSON lines (empty disables it)")
+
+	return map[string]func(*Config) error{
+		"app-id":            func(c *Config) error { c.Telegram.AppID = *appID; return nil },
+		"app-hash":          func(c *Config) error { c.Telegram.AppHash = *appHash; return nil },
+		"accounts":          func(c *Config) error { c.Telegram.Accounts = splitList(*accounts); return nil },
+		"media-workers":     func(c *Config) error { c.Telegram.MediaWorkers = *mediaWorkers; return nil },
+		"proxy":             func(c *Config) error { c.Telegram.Proxy = *tgProxy; return nil },
+		"session-dir":       func(c *Config) error { c.Session.Dir = *sessionDir; return nil },
+		"host":              func(c *Config) error { c.Server.Host = *host; return nil },
+		"port":              func(c *Config) error { c.Server.Port = *port; return nil },
+		"static-dir":        func(c *Config) error { c.Server.StaticDir = *staticDir; return nil },
+		"page-size":         func(c *Config) error { c.Messages.DefaultPageSize = *defaultPageSize; return nil },
+		"max-page-size":     func(c *Config) error { c.Messages.MaxPageSize = *maxPageSize; return nil },
+		"media-cache-bytes": func(c *Config) error { c.Cache.MediaMaxBytes = *mediaCache; return nil },
+		"media-cache-ttl":   func(c *Config) error { c.Cache.MediaTTL = *mediaTTL; return nil },
+		"log-level":         func(c *Config) error { c.Log.Level = *logLevel; return nil },
+		"log-format":        func(c *Config) error { c.Log.Format = *logFormat; return nil },
+		"audit-log":         func(c *Config) error { c.Log.AuditFile = *auditLog; return nil },
+	}
+}
+
+// Validate checks that the configuration is complete and consistent.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Telegram.AppID <= 0 || c.Telegram.AppHash == "" {
+		errs = append(errs, errors.New("telegram app_id and app_hash are required (TG_APP_ID/TG_APP_HASH)"))
+	}
+	if len(c.Telegram.Accounts) == 0 {
+		errs = append(errs, errors.New("at least one account is required"))
+	}
+	if c.Telegram.MediaWorkers < 1 || c.Telegram.MediaWorkers > 32 {
+		errs = append(errs, fmt.Errorf("media workers must be between 1 and 32, got %d", c.Telegram.MediaWorkers))
+	}
+	if _, err := c.TelegramProxy(); err != nil {
+		errs = append(errs, err)
+	}
+	seen := map[string]bool{}
+	for _, name := range c.Telegram.Accounts {
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("duplicate account %q", name))
+		}
+		seen[name] = true
+	}
+
+	if c.Session.Dir == "" {
+		errs = append(errs, errors.New("session dir must not be empty"))
+	}
+	if c.Session.Passphrase != "" && c.Session.Keyfile != "" {
+		errs = append(errs, errors.New("set only one of session passphrase and keyfile"))
+	}
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("invalid port %d", c.Server.Port))
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls cert_file and key_file must be set together"))
+	}
+	if c.Server.TLS.RedirectPort < 0 || c.Server.TLS.RedirectPort > 65535 {
+		errs = append(errs, fmt.Errorf("invalid redirect port %d", c.Server.TLS.RedirectPort))
+	}
+
+	if c.Messages.MaxPageSize < 1 || c.Messages.MaxPageSize > 100 {
+		errs = append(errs, fmt.Errorf("max page size must be between 1 and 100, got %d", c.Messages.MaxPageSize))
+	}
+	if c.Messages.DefaultPageSize < 1 || c.Messages.DefaultPageSize > c.Messages.MaxPageSize {
+		errs = append(errs, fmt.Errorf("default page size must be between 1 and the max page size, got %d", c.Messages.DefaultPageSize))
+	}
+
+	if c.Cache.MediaMaxBytes < 0 {
+		errs = append(errs, errors.New("media cache size must not be negative"))
+	}
+	if c.Cache.MediaTTL < 0 {
+		errs = append(errs, errors.New("media cache ttl must not be negative"))
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("invalid log level %q", c.Log.Level))
+	}
+	switch c.Log.Format {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("invalid log format %q", c.Log.Format))
+	}
+
+	return errors.Join(errs...)
+}
+
+// SessionSecret returns the secret used to encrypt session files, read from
+// the keyfile if one is configured, or nil if encryption is disabled.
+func (c *Config) SessionSecret() ([]byte, error) {
+	switch {
+	case c.Session.Passphrase != "":
+		return []byte(c.Session.Passphrase), nil
+	case c.Session.Keyfile != "":
+		secret, err := os.ReadFile(c.Session.Keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read session keyfile: %w", err)
+		}
+		if len(secret) == 0 {
+			return nil, errors.New("session keyfile is empty")
+		}
+		return secret, nil
+	default:
+		return nil, nil
+	}
+}
+
+// TelegramProxy returns the proxy to connect to Telegram through, or nil to
+// connect directly.
+func (c *Config) TelegramProxy() (*proxy.Proxy, error) {
+	if c.Telegram.Proxy == "" {
+		return nil, nil
+	}
+	return proxy.Parse(c.Telegram.Proxy)
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
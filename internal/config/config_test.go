@@ -0,0 +1,261 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envVars are all environment variables read by Load.
+var envVars = []string{
+	"CONFIG", "TG_APP_ID", "TG_APP_HASH", "TG_ACCOUNTS", "TG_MEDIA_WORKERS", "TG_PROXY",
+	"SESSION_DIR", "TG_SESSION_PASSPHRASE", "TG_SESSION_KEYFILE",
+	"HOST", "PORT", "AUTH_PASSWORD", "STATIC_DIR", "TLS_CERT", "TLS_KEY", "TLS_SELF_SIGNED", "TLS_DIR", "HTTP_REDIRECT_PORT",
+	"LOG_LEVEL", "LOG_FORMAT", "AUDIT_LOG",
+}
+
+// clearEnv unsets the variables read by Load for the duration of the test,
+// so the environment running the tests does not leak in.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range envVars {
+		t.Setenv(name, "") // Restores the original value when the test ends
+		os.Unsetenv(name)
+	}
+}
+
+// writeFile writes a config file into a temporary directory.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("TG_APP_ID", "1")
+	t.Setenv("TG_APP_HASH", "hash")
+
+	cfg, rest, err := Load([]string{"list", "-limit", "5"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := Default()
+	want.Telegram.AppID, want.Telegram.AppHash = 1, "hash"
+	if cfg.Server != want.Server || cfg.Messages != want.Messages || cfg.Cache != want.Cache || cfg.Log != want.Log ||
+		!slices.Equal(cfg.Telegram.Accounts, want.Telegram.Accounts) {
+		t.Errorf("got %+v, want the defaults %+v", cfg, want)
+	}
+	if !slices.Equal(rest, []string{"list", "-limit", "5"}) {
+		t.Errorf("remaining args = %v", rest)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "config.yaml", `
+telegram:
+  app_id: 1
+  app_hash: from-file
+  accounts: [alice, bob]
+server:
+  port: 9000
+  host: 10.0.0.1
+log:
+  level: debug
+  format: json
+`)
+
+	// File only
+	cfg, _, err := Load([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Telegram.AppHash != "from-file" || cfg.Server.Port != 9000 || cfg.Server.Host != "10.0.0.1" ||
+		!slices.Equal(cfg.Telegram.Accounts, []string{"alice", "bob"}) {
+		t.Errorf("file settings not applied: %+v", cfg)
+	}
+	if cfg.Messages.MaxPageSize != 100 {
+		t.Errorf("settings missing from the file lost their defaults: %+v", cfg.Messages)
+	}
+
+	// The environment overrides the file, and the file can come from CONFIG
+	t.Setenv("CONFIG", path)
+	t.Setenv("PORT", "9100")
+	t.Setenv("TG_ACCOUNTS", "carol, dave")
+	t.Setenv("HOST", "")
+	cfg, _, err = Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != 9100 || cfg.Server.Host != "" || !slices.Equal(cfg.Telegram.Accounts, []string{"carol", "dave"}) {
+		t.Errorf("environment did not override the file: %+v", cfg)
+	}
+	if cfg.Log.Level != "debug" {
+		t.Errorf("file setting not in the environment was lost: %+v", cfg.Log)
+	}
+
+	// Flags override both, but only the ones given
+	cfg, _, err = Load([]string{"-port", "9200", "-host", "127.0.0.1", "-log-level", "warn"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Server.Port != 9200 || cfg.Server.Host != "127.0.0.1" || cfg.Log.Level != "warn" {
+		t.Errorf("flags did not override: %+v", cfg)
+	}
+	if cfg.Log.Format != "json" || !slices.Equal(cfg.Telegram.Accounts, []string{"carol", "dave"}) {
+		t.Errorf("flags that were not given overrode settings: %+v", cfg)
+	}
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"config.yaml", `
+telegram:
+  app_id: 7
+  app_hash: abc
+  accounts: [work]
+cache:
+  media_ttl: 90s
+server:
+  tls:
+    self_signed: true
+`},
+		{"config.yml", `
+telegram: {app_id: 7, app_hash: abc, accounts: [work]}
+cache: {media_ttl: 90s}
+server: {tls: {self_signed: true}}
+`},
+		{"config.toml", `
+[telegram]
+app_id = 7
+app_hash = "abc"
+accounts = ["work"]
+
+[cache]
+media_ttl = "90s"
+
+[server.tls]
+self_signed = true
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			cfg, _, err := Load([]string{"-config", writeFile(t, tt.name, tt.content)})
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if cfg.Telegram.AppID != 7 || cfg.Telegram.AppHash != "abc" || !slices.Equal(cfg.Telegram.Accounts, []string{"work"}) ||
+				cfg.Cache.MediaTTL != 90*time.Second || !cfg.Server.TLS.SelfSigned {
+				t.Errorf("got %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"missing file", []string{"-config", "missing.yaml"}, nil, "failed to read config file"},
+		{"invalid env number", nil, map[string]string{"PORT": "eighty"}, "invalid PORT"},
+		{"unknown flag", []string{"-no-such-flag"}, nil, "not defined"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("TG_APP_ID", "1")
+			t.Setenv("TG_APP_HASH", "hash")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, _, err := Load(tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown file type", func(t *testing.T) {
+		clearEnv(t)
+		_, _, err := Load([]string{"-config", writeFile(t, "config.json", "{}")})
+		if err == nil || !strings.Contains(err.Error(), "unsupported config file") {
+			t.Errorf("err = %v", err)
+		}
+	})
+
+	t.Run("invalid yaml", func(t *testing.T) {
+		clearEnv(t)
+		_, _, err := Load([]string{"-config", writeFile(t, "bad.yaml", "telegram: [")})
+		if err == nil || !strings.Contains(err.Error(), "failed to parse config file") {
+			t.Errorf("err = %v", err)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		c := Default()
+		c.Telegram.AppID, c.Telegram.AppHash = 1, "hash"
+		return c
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"no app id", func(c *Config) { c.Telegram.AppID = 0 }, "app_id and app_hash are required"},
+		{"no app hash", func(c *Config) { c.Telegram.AppHash = "" }, "app_id and app_hash are required"},
+		{"no accounts", func(c *Config) { c.Telegram.Accounts = nil }, "at least one account"},
+		{"duplicate account", func(c *Config) { c.Telegram.Accounts = []string{"a", "a"} }, `duplicate account "a"`},
+		{"media workers", func(c *Config) { c.Telegram.MediaWorkers = 33 }, "media workers"},
+		{"proxy", func(c *Config) { c.Telegram.Proxy = "ftp://proxy:21" }, "unsupported proxy"},
+		{"session dir", func(c *Config) { c.Session.Dir = "" }, "session dir"},
+		{"passphrase and keyfile", func(c *Config) { c.Session.Passphrase, c.Session.Keyfile = "p", "k" }, "only one of session passphrase and keyfile"},
+		{"port", func(c *Config) { c.Server.Port = 70000 }, "invalid port"},
+		{"half tls", func(c *Config) { c.Server.TLS.CertFile = "cert.pem" }, "set together"},
+		{"redirect port", func(c *Config) { c.Server.TLS.RedirectPort = -1 }, "invalid redirect port"},
+		{"max page size", func(c *Config) { c.Messages.MaxPageSize = 101 }, "max page size"},
+		{"default page size", func(c *Config) { c.Messages.DefaultPageSize = 200 }, "default page size"},
+		{"cache size", func(c *Config) { c.Cache.MediaMaxBytes = -1 }, "cache size"},
+		{"cache ttl", func(c *Config) { c.Cache.MediaTTL = -time.Second }, "cache ttl"},
+		{"log level", func(c *Config) { c.Log.Level = "verbose" }, "invalid log level"},
+		{"log format", func(c *Config) { c.Log.Format = "xml" }, "invalid log format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(c)
+			if err := c.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+
+	// All problems are reported at once
+	c := valid()
+	c.Server.Port = 0
+	c.Log.Level = "loud"
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "invalid port") || !strings.Contains(err.Error(), "invalid log level") {
+		t.Errorf("err = %v, want both problems", err)
+	}
+}
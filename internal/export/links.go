@@ -0,0 +1,214 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"telegram-manager/internal/tg"
+)
+
+// Format is an output format for exported links.
+type Format string
+
+const (
+	FormatBookmarks Format = "bookmarks" // Netscape bookmark HTML
+	FormatJSON      Format = "json"
+	FormatCSV       Format = "csv"
+)
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatBookmarks:
+		return "text/html; charset=utf-8"
+	case FormatJSON:
+		return "application/json"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Extension returns the file extension (without a dot) of the format.
+func (f Format) Extension() string {
+	switch f {
+	case FormatBookmarks:
+		return "html"
+	case FormatJSON:
+		return "json"
+	case FormatCSV:
+		return "csv"
+	default:
+		return "bin"
+	}
+}
+
+// ParseFormat parses a format name. "html" is accepted as an alias for bookmarks.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case FormatBookmarks, "html":
+		return FormatBookmarks, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("unknown export format: %q", s)
+	}
+}
+
+// WriteLinks writes links to w in the given format.
+func WriteLinks(w io.Writer, format Format, links []tg.Link) error {
+	switch format {
+	case FormatBookmarks:
+		return LinksBookmarks(w, links, "Telegram Saved Messages")
+	case FormatJSON:
+		return LinksJSON(w, links)
+	case FormatCSV:
+		return LinksCSV(w, links)
+	default:
+		return fmt.Errorf("unknown export format: %q", format)
+	}
+}
+
+// linkRecord is the exported representation of a link, with readable dates.
+type linkRecord struct {
+	URL         string `json:"url"`
+	Domain      string `json:"domain"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	SiteName    string `json:"site_name"`
+	SavedAt     string `json:"saved_at"`      // When the link was first saved
+	LastSavedAt string `json:"last_saved_at"` // When the link was last saved again
+	MessageID   int    `json:"message_id"`    // The message the link was first saved in
+	MessageIDs  []int  `json:"message_ids"`   // Every message containing the link
+}
+
+func newLinkRecord(link tg.Link) linkRecord {
+	r := linkRecord{
+		URL:         link.URL,
+		Domain:      link.Domain,
+		Title:       link.Title,
+		Description: link.Description,
+		SiteName:    link.SiteName,
+		SavedAt:     formatDate(link.FirstSaved),
+		LastSavedAt: formatDate(link.LastSaved),
+		MessageIDs:  link.MessageIDs,
+	}
+
+	// History is walked newest first, so the oldest message comes last
+	if len(link.MessageIDs) > 0 {
+		r.MessageID = link.MessageIDs[len(link.MessageIDs)-1]
+	}
+
+	return r
+}
+
+// LinksJSON writes links as an indented JSON array.
+func LinksJSON(w io.Writer, links []tg.Link) error {
+	records := make([]linkRecord, 0, len(links))
+	for _, link := range links {
+		records = append(records, newLinkRecord(link))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// LinksCSV writes links as CSV with a header row.
+// Dates are RFC 3339 in UTC, message IDs are separated by spaces.
+func LinksCSV(w io.Writer, links []tg.Link) error {
+	cw := csv.NewWriter(w)
+
+	// The first columns are those of the original export; new ones go last
+	// so that spreadsheets and scripts reading them by position keep working
+	header := []string{"url", "domain", "title", "first_saved", "last_saved", "message_ids", "description", "site_name", "message_id"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		r := newLinkRecord(link)
+
+		ids := make([]string, len(r.MessageIDs))
+		for i, id := range r.MessageIDs {
+			ids[i] = strconv.Itoa(id)
+		}
+
+		record := []string{
+			r.URL,
+			r.Domain,
+			r.Title,
+			r.SavedAt,
+			r.LastSavedAt,
+			strings.Join(ids, " "),
+			r.Description,
+			r.SiteName,
+			strconv.Itoa(r.MessageID),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// LinksBookmarks writes links as a Netscape bookmark file, the format
+// understood by the bookmark import of every major browser. All links are
+// placed into a single folder named folder; descriptions become <DD> notes.
+func LinksBookmarks(w io.Writer, links []tg.Link, folder string) error {
+	var b strings.Builder
+
+	b.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	b.WriteString("<!-- This is an automatically generated file.\n     It will be read and overwritten.\n     DO NOT EDIT! -->\n")
+	b.WriteString("<META HTTP-EQUIV=\"Content-Type\" CONTENT=\"text/html; charset=UTF-8\">\n")
+	b.WriteString("<TITLE>Bookmarks</TITLE>\n")
+	b.WriteString("<H1>Bookmarks</H1>\n")
+	b.WriteString("<DL><p>\n")
+	fmt.Fprintf(&b, "    <DT><H3>%s</H3>\n", html.EscapeString(folder))
+	b.WriteString("    <DL><p>\n")
+
+	for _, link := range links {
+		title := link.Title
+		if title == "" {
+			title = link.URL
+		}
+		fmt.Fprintf(&b, "        <DT><A HREF=\"%s\" ADD_DATE=\"%d\" LAST_MODIFIED=\"%d\">%s</A>\n",
+			html.EscapeString(link.URL), link.FirstSaved, link.LastSaved, html.EscapeString(title))
+
+		note := link.Description
+		if link.SiteName != "" {
+			if note != "" {
+				note = link.SiteName + " — " + note
+			} else {
+				note = link.SiteName
+			}
+		}
+		if note != "" {
+			fmt.Fprintf(&b, "        <DD>%s\n", html.EscapeString(note))
+		}
+	}
+
+	b.WriteString("    </DL><p>\n")
+	b.WriteString("</DL><p>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatDate(unix int) string {
+	if unix == 0 {
+		return ""
+	}
+	return time.Unix(int64(unix), 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,256 @@
+// Package proxy routes Telegram connections through a SOCKS5 proxy, an HTTP
+// proxy (CONNECT) or an MTProxy, for networks where Telegram is blocked.
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gotd/td/telegram/dcs"
+	netproxy "golang.org/x/net/proxy"
+)
+
+// Kinds of proxies.
+const (
+	KindSOCKS5  = "socks5"
+	KindHTTP    = "http"
+	KindMTProxy = "mtproxy"
+)
+
+// Proxy is a parsed proxy setting.
+type Proxy struct {
+	Kind string
+	// Addr is the host:port of the proxy.
+	Addr string
+	// User and Password authenticate with SOCKS5 and HTTP proxies.
+	User     string
+	Password string
+	// Secret is the MTProxy secret.
+	Secret []byte
+
+	resolver dcs.Resolver
+}
+
+// Parse parses a proxy URL:
+//
+//	socks5://[user:password@]host:port
+//	http://[user:password@]host:port
+//	mtproxy://host:port?secret=<hex>
+//
+// MTProxy links as shared by Telegram (tg://proxy?server=...&port=...&secret=...
+// or https://t.me/proxy?...) are accepted as well.
+func Parse(raw string) (*Proxy, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		// *url.Error quotes the whole URL, password or secret included
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	p := &Proxy{}
+	switch {
+	case u.Scheme == "socks5" || u.Scheme == "socks5h":
+		p.Kind = KindSOCKS5
+	case u.Scheme == "http":
+		p.Kind = KindHTTP
+	case u.Scheme == "mtproxy":
+		p.Kind = KindMTProxy
+	case u.Scheme == "tg" && u.Host == "proxy",
+		u.Scheme == "https" && u.Host == "t.me" && u.Path == "/proxy":
+		return parseLink(u)
+	default:
+		return nil, fmt.Errorf("unsupported proxy %q: use socks5://, http://, mtproxy:// or a tg://proxy link", redact(u))
+	}
+
+	if u.Port() == "" || u.Hostname() == "" {
+		return nil, fmt.Errorf("proxy %q needs a host and port", redact(u))
+	}
+	p.Addr = u.Host
+
+	if p.Kind == KindMTProxy {
+		if err := p.setSecret(u.Query().Get("secret")); err != nil {
+			return nil, err
+		}
+	} else if u.User != nil {
+		p.User = u.User.Username()
+		p.Password, _ = u.User.Password()
+	}
+
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseLink parses an MTProxy link shared by Telegram.
+func parseLink(u *url.URL) (*Proxy, error) {
+	q := u.Query()
+	server, port := q.Get("server"), q.Get("port")
+	if server == "" || port == "" {
+		return nil, errors.New("proxy link needs a server and port")
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return nil, fmt.Errorf("invalid proxy port %q", port)
+	}
+
+	p := &Proxy{Kind: KindMTProxy, Addr: net.JoinHostPort(server, port)}
+	if err := p.setSecret(q.Get("secret")); err != nil {
+		return nil, err
+	}
+	if err := p.init(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// setSecret decodes an MTProxy secret. Telegram shares them in hex, and
+// fake-TLS ("ee") secrets sometimes in base64.
+func (p *Proxy) setSecret(s string) error {
+	if s == "" {
+		return errors.New("mtproxy needs a secret")
+	}
+
+	if secret, err := hex.DecodeString(s); err == nil {
+		p.Secret = secret
+		return nil
+	}
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding, base64.StdEncoding, base64.RawStdEncoding} {
+		if secret, err := enc.DecodeString(s); err == nil {
+			p.Secret = secret
+			return nil
+		}
+	}
+	return errors.New("invalid mtproxy secret: not hex or base64")
+}
+
+// init builds the resolver, which also validates an MTProxy secret.
+func (p *Proxy) init() error {
+	if p.Kind == KindMTProxy {
+		r, err := dcs.MTProxy(p.Addr, p.Secret, dcs.MTProxyOptions{})
+		if err != nil {
+			return fmt.Errorf("invalid mtproxy secret: %w", err)
+		}
+		p.resolver = r
+		return nil
+	}
+
+	p.resolver = dcs.Plain(dcs.PlainOptions{Dial: p.Dial})
+	return nil
+}
+
+// Resolver returns the gotd resolver connecting to Telegram through the
+// proxy, for telegram.Options.
+func (p *Proxy) Resolver() dcs.Resolver {
+	return p.resolver
+}
+
+// Dial connects to addr through a SOCKS5 or HTTP proxy. MTProxies do not
+// tunnel arbitrary connections; their resolver dials them itself.
+func (p *Proxy) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch p.Kind {
+	case KindSOCKS5:
+		var auth *netproxy.Auth
+		if p.User != "" {
+			auth = &netproxy.Auth{User: p.User, Password: p.Password}
+		}
+		dialer, err := netproxy.SOCKS5("tcp", p.Addr, auth, &net.Dialer{})
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dialer.(netproxy.ContextDialer).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect through socks5 proxy: %w", err)
+		}
+		return conn, nil
+	case KindHTTP:
+		return p.dialHTTP(ctx, addr)
+	default:
+		return nil, fmt.Errorf("cannot tunnel connections through %s", p.Kind)
+	}
+}
+
+// dialHTTP opens a tunnel to addr with an HTTP CONNECT request.
+func (p *Proxy) dialHTTP(ctx context.Context, addr string) (_ net.Conn, rerr error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to http proxy: %w", err)
+	}
+	defer func() {
+		if rerr != nil {
+			conn.Close()
+		}
+	}()
+
+	// The handshake must not outlive ctx; the tunnel itself may
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if p.User != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(p.User + ":" + p.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("failed to send CONNECT to http proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read http proxy response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http proxy refused CONNECT: %s", resp.Status)
+	}
+
+	if !stop() {
+		return nil, ctx.Err()
+	}
+	if br.Buffered() > 0 {
+		// The server spoke first and the reader holds some of it
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn reads what the CONNECT response reader buffered first.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// String describes the proxy without its credentials, for logs.
+func (p *Proxy) String() string {
+	return p.Kind + "://" + p.Addr
+}
+
+// redact removes credentials and query (which holds MTProxy secrets) from
+// a URL for error messages.
+func redact(u *url.URL) string {
+	c := *u
+	c.User = nil
+	c.RawQuery = ""
+	return c.String()
+}
@@ -0,0 +1,275 @@
+// Package proxytest provides minimal local SOCKS5 and HTTP CONNECT proxies
+// for tests, in the spirit of net/http/httptest.
+package proxytest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Server is a proxy listening on a local port.
+type Server struct {
+	// Addr is the host:port to connect to the proxy.
+	Addr string
+	// User and Password are required from clients when User is set.
+	User     string
+	Password string
+
+	listener net.Listener
+	handle   func(s *Server, conn net.Conn) error
+	tunnels  atomic.Int64
+	wg       sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+// NewSOCKS5 starts a SOCKS5 proxy. With a user it only accepts
+// username/password authentication.
+func NewSOCKS5(user, password string) *Server {
+	return start(user, password, (*Server).serveSOCKS5)
+}
+
+// NewHTTP starts an HTTP proxy supporting CONNECT only. With a user it
+// requires Basic proxy authorization.
+func NewHTTP(user, password string) *Server {
+	return start(user, password, (*Server).serveHTTP)
+}
+
+func start(user, password string, handle func(s *Server, conn net.Conn) error) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("proxytest: failed to listen: " + err.Error())
+	}
+
+	s := &Server{
+		Addr:     l.Addr().String(),
+		User:     user,
+		Password: password,
+		listener: l,
+		handle:   handle,
+		conns:    map[net.Conn]struct{}{},
+	}
+	s.wg.Add(1)
+	go s.accept()
+	return s
+}
+
+// Tunnels returns the number of tunnels opened so far.
+func (s *Server) Tunnels() int {
+	return int(s.tunnels.Load())
+}
+
+// Close stops the proxy and closes every connection through it.
+func (s *Server) Close() {
+	s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.track(conn)
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(conn)
+			defer conn.Close()
+			s.handle(s, conn)
+		}()
+	}
+}
+
+func (s *Server) track(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns[conn] = struct{}{}
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// tunnel dials addr and copies between it and the client until either side
+// closes. client is read from r, which may hold buffered bytes.
+func (s *Server) tunnel(conn net.Conn, r io.Reader, target net.Conn) {
+	s.track(target)
+	defer s.untrack(target)
+	defer target.Close()
+	s.tunnels.Add(1)
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(target, r)
+		target.Close()
+		close(done)
+	}()
+	io.Copy(conn, target)
+	conn.Close()
+	<-done
+}
+
+// serveSOCKS5 implements the CONNECT command of RFC 1928, with RFC 1929
+// username/password authentication.
+func (s *Server) serveSOCKS5(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+
+	var hello [2]byte
+	if _, err := io.ReadFull(r, hello[:]); err != nil {
+		return err
+	}
+	methods := make([]byte, hello[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return err
+	}
+
+	want := byte(0x00) // No authentication
+	if s.User != "" {
+		want = 0x02 // Username/password
+	}
+	offered := false
+	for _, m := range methods {
+		offered = offered || m == want
+	}
+	if hello[0] != 5 || !offered {
+		conn.Write([]byte{5, 0xff})
+		return errors.New("no acceptable method")
+	}
+	conn.Write([]byte{5, want})
+
+	if s.User != "" {
+		user, password, err := readCredentials(r)
+		if err != nil {
+			return err
+		}
+		if user != s.User || password != s.Password {
+			conn.Write([]byte{1, 1})
+			return errors.New("wrong credentials")
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	var req [4]byte
+	if _, err := io.ReadFull(r, req[:]); err != nil {
+		return err
+	}
+	if req[1] != 1 { // CONNECT
+		conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0})
+		return errors.New("unsupported command")
+	}
+
+	var host string
+	switch req[3] {
+	case 1, 4: // IPv4, IPv6
+		ip := make(net.IP, 4)
+		if req[3] == 4 {
+			ip = make(net.IP, 16)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return err
+		}
+		host = ip.String()
+	case 3: // Domain name
+		n, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		name := make([]byte, n)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return err
+		}
+		host = string(name)
+	default:
+		conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+		return errors.New("unsupported address type")
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(r, port[:]); err != nil {
+		return err
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return err
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	s.tunnel(conn, r, target)
+	return nil
+}
+
+func readCredentials(r *bufio.Reader) (string, string, error) {
+	readField := func() (string, error) {
+		n, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return string(b), err
+	}
+
+	if _, err := r.ReadByte(); err != nil { // Subnegotiation version
+		return "", "", err
+	}
+	user, err := readField()
+	if err != nil {
+		return "", "", err
+	}
+	password, err := readField()
+	return user, password, err
+}
+
+// serveHTTP answers a single CONNECT request and tunnels the connection.
+func (s *Server) serveHTTP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return err
+	}
+
+	if req.Method != http.MethodConnect {
+		io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+		return errors.New("not a CONNECT request")
+	}
+	if s.User != "" {
+		want := "Basic " + base64.StdEncoding.EncodeToString([]byte(s.User+":"+s.Password))
+		if req.Header.Get("Proxy-Authorization") != want {
+			io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return errors.New("wrong credentials")
+		}
+	}
+
+	target, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return err
+	}
+	io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+
+	s.tunnel(conn, r, target)
+	return nil
+}
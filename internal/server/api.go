@@ -0,0 +1,294 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gotd/td/tgerr"
+
+	"telegram-manager/internal/tg"
+)
+
+const (
+	// apiPrefix is the current, versioned API namespace.
+	apiPrefix = "/api/v1"
+	// legacyAPIPrefix serves the same handlers for clients written before
+	// the API was versioned, with the old plain-text errors and status
+	// codes (see legacyAPI). Deprecated, use apiPrefix.
+	legacyAPIPrefix = "/api"
+)
+
+// Error codes of the JSON error envelope. Telegram errors that are the
+// caller's fault use the lowercased RPC error type instead, e.g.
+// "message_id_invalid".
+const (
+	codeBadRequest       = "bad_request"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeConflict         = "conflict"
+	codeTooLarge         = "too_large"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeFloodWait        = "flood_wait"
+	codeNotReady         = "not_ready"
+	codeTelegramError    = "telegram_error"
+	codeInternalError    = "internal_error"
+)
+
+// APIError describes why a request failed.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// RetryAfter is set for flood waits: the number of seconds Telegram
+	// wants us to wait before trying again.
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// ErrorResponse is the body of every failed API request.
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes the JSON error envelope.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorResponse{Error: APIError{Code: code, Message: message}})
+}
+
+// writeMethodNotAllowed rejects a request made with the wrong HTTP method.
+func writeMethodNotAllowed(w http.ResponseWriter) {
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+}
+
+// writeClientError turns an error returned by tg.Client into a response.
+// Flood waits become 429 with Retry-After, errors caused by the request
+// become 400, everything else keeps the generic message.
+func writeClientError(w http.ResponseWriter, err error, message string) {
+	if d, ok := tgerr.AsFloodWait(err); ok {
+		seconds := int(math.Ceil(d.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		writeJSON(w, http.StatusTooManyRequests, ErrorResponse{Error: APIError{
+			Code:       codeFloodWait,
+			Message:    fmt.Sprintf("Telegram rate limit hit, retry in %d seconds", seconds),
+			RetryAfter: seconds,
+		}})
+		return
+	}
+
+	if errors.Is(err, tg.ErrNotReady) {
+		writeError(w, http.StatusServiceUnavailable, codeNotReady, "Account is not connected yet")
+		return
+	}
+
+	if rpcErr, ok := tgerr.As(err); ok {
+		if rpcErr.Code == http.StatusBadRequest {
+			writeError(w, http.StatusBadRequest, strings.ToLower(rpcErr.Type), message+": "+rpcErr.Type)
+			return
+		}
+		writeError(w, http.StatusBadGateway, codeTelegramError, message)
+		return
+	}
+
+	writeError(w, http.StatusInternalServerError, codeInternalError, message)
+}
+
+// route is an API endpoint. The same table registers the handlers and
+// generates the OpenAPI document, so the two cannot drift apart.
+type route struct {
+	method  string
+	path    string // Relative to the API prefix
+	summary string
+	params  []param
+	// request and response are zero values of the JSON body types, nil if
+	// there is no JSON body.
+	request  interface{}
+	response interface{}
+	// requestType and responseType override the JSON content type for
+	// form posts, uploads and downloads.
+	requestType  string
+	responseType string
+	handler      http.HandlerFunc
+}
+
+// param is a query parameter.
+type param struct {
+	name        string
+	typ         string // OpenAPI type: "integer", "string", ...
+	description string
+	required    bool
+}
+
+// accountParam is accepted by every endpoint working on an account's data.
+var accountParam = param{name: "account", typ: "string", description: "Account name (alternative to the X-Account header). Defaults to the first account."}
+
+func (s *Server) routes() []route {
+	return []route{
+		{
+			method: http.MethodGet, path: "/messages", summary: "List Saved Messages a page at a time",
+			params: []param{
+				accountParam,
+				{name: "cursor", typ: "string", description: "next_cursor or prev_cursor of a previous page. Takes precedence over order and date"},
+				{name: "order", typ: "string", description: "desc (newest first, default) or asc (oldest first)"},
+				{name: "date", typ: "integer", description: "Unix time to start at instead of the newest or oldest message"},
+				{name: "limit", typ: "integer", description: "Number of messages. Defaults to the configured page size. Albums cut by the limit are completed"},
+				{name: "offset_id", typ: "integer", description: "Deprecated, use cursor. Only return messages older than this ID"},
+				{name: "add_offset", typ: "integer", description: "Deprecated, use cursor. Offset relative to offset_id"},
+			},
+			response: MessagesResponse{},
+			handler:  s.handleGetMessages,
+		},
+		{
+			method: http.MethodPost, path: "/delete", summary: "Delete messages",
+			params:  []param{accountParam},
+			request: DeleteRequest{},
+			handler: s.handleDeleteMessages,
+		},
+		{
+			method: http.MethodGet, path: "/media", summary: "Download the media of a message",
+			params: []param{
+				accountParam,
+				{name: "id", typ: "integer", description: "Message ID", required: true},
+				{name: "priority", typ: "string", description: "normal (default) or high for media on screen, which is downloaded first"},
+			},
+			responseType: "application/octet-stream",
+			handler:      s.handleGetMedia,
+		},
+		{
+			method: http.MethodPost, path: "/send", summary: "Send a text note",
+			params:   []param{accountParam},
+			request:  SendRequest{},
+			response: SendResponse{},
+			handler:  s.handleSendMessage,
+		},
+		{
+			method: http.MethodPost, path: "/upload", summary: "Upload a photo or file",
+			params:      []param{accountParam},
+			request:     UploadForm{},
+			requestType: "multipart/form-data",
+			response:    SendResponse{},
+			handler:     s.handleUploadFile,
+		},
+		{
+			method: http.MethodPost, path: "/edit", summary: "Edit the text of a message",
+			params:  []param{accountParam},
+			request: EditRequest{},
+			handler: s.handleEditMessage,
+		},
+		{
+			method: http.MethodGet, path: "/pinned", summary: "List pinned messages",
+			params:   []param{accountParam},
+			response: PinnedResponse{},
+			handler:  s.handleGetPinned,
+		},
+		{
+			method: http.MethodPost, path: "/pin", summary: "Pin or unpin a message",
+			params:  []param{accountParam},
+			request: PinRequest{},
+			handler: s.handlePinMessage,
+		},
+		{
+			method: http.MethodGet, path: "/links", summary: "List links found in Saved Messages",
+			params: []param{
+				accountParam,
+				{name: "domain", typ: "string", description: "Only links from this domain"},
+				{name: "q", typ: "string", description: "Only links whose URL or title contains this text"},
+			},
+			response: LinksResponse{},
+			handler:  s.handleGetLinks,
+		},
+		{
+			method: http.MethodGet, path: "/links/export", summary: "Export links as bookmarks, JSON or CSV",
+			params: []param{
+				accountParam,
+				{name: "format", typ: "string", description: "bookmarks (default), json or csv"},
+				{name: "domain", typ: "string", description: "Only links from this domain"},
+				{name: "q", typ: "string", description: "Only links whose URL or title contains this text"},
+			},
+			responseType: "application/octet-stream",
+			handler:      s.handleExportLinks,
+		},
+		{
+			method: http.MethodGet, path: "/stats", summary: "Storage usage statistics",
+			params: []param{
+				accountParam,
+				{name: "top", typ: "integer", description: "Number of largest files to list (default 20)"},
+			},
+			response: tg.Stats{},
+			handler:  s.handleGetStats,
+		},
+		{
+			method: http.MethodPost, path: "/login", summary: "Log in to the web UI with the password",
+			request:     LoginForm{},
+			requestType: "application/x-www-form-urlencoded",
+			handler:     s.handleLogin,
+		},
+		{
+			method: http.MethodPost, path: "/logout", summary: "Log out of the web UI",
+			handler: s.handleLogout,
+		},
+		{
+			method: http.MethodGet, path: "/session", summary: "Web UI session information",
+			response: SessionResponse{},
+			handler:  s.handleSession,
+		},
+		{
+			method: http.MethodGet, path: "/audit", summary: "Recent deletions and other changes, newest first",
+			params: []param{
+				{name: "account", typ: "string", description: "Only entries of this account"},
+				{name: "action", typ: "string", description: "Only this action: delete, edit, send, upload, pin, unpin, logout, session_export or session_import"},
+				{name: "limit", typ: "integer", description: "Number of entries (default 100, at most 1000)"},
+			},
+			response: AuditResponse{},
+			handler:  s.handleGetAudit,
+		},
+		{
+			method: http.MethodPost, path: "/telegram-session/export", summary: "Export the Telegram session as an encrypted string",
+			params:   []param{accountParam},
+			request:  SessionExportRequest{},
+			response: SessionExportResponse{},
+			handler:  s.handleExportSession,
+		},
+		{
+			method: http.MethodPost, path: "/telegram-session/import", summary: "Import a Telegram session exported by another install",
+			params:  []param{accountParam},
+			request: SessionImportRequest{},
+			handler: s.handleImportSession,
+		},
+		{
+			method: http.MethodGet, path: "/accounts", summary: "List configured accounts",
+			response: AccountsResponse{},
+			handler:  s.handleGetAccounts,
+		},
+	}
+}
+
+// registerAPI adds every route to mux under both API prefixes.
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	for _, rt := range s.routes() {
+		handler := instrument(rt.path, rt.handler)
+		mux.HandleFunc(apiPrefix+rt.path, handler)
+		mux.HandleFunc(legacyAPIPrefix+rt.path, handler)
+	}
+	mux.HandleFunc(apiPrefix+"/openapi.json", s.handleOpenAPI)
+
+	// Without this, unknown API paths would fall through to the web UI
+	mux.HandleFunc(legacyAPIPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, codeNotFound, "Unknown API endpoint")
+	})
+}
+
+// isAPIPath reports whether p belongs to the API (under either prefix).
+func isAPIPath(p string) bool {
+	return strings.HasPrefix(p, legacyAPIPrefix+"/")
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"telegram-manager/static"
+)
+
+const (
+	// Hashed asset URLs change whenever their content does, so browsers may
+	// keep them forever.
+	immutableCache = "public, max-age=31536000, immutable"
+	// Pages and unhashed URLs are revalidated on every load (cheap thanks to ETags).
+	revalidateCache = "no-cache"
+)
+
+// asset is a file of the web UI held in memory.
+type asset struct {
+	name        string // Original name, e.g. "app.js"
+	content     []byte
+	contentType string
+	etag        string
+	immutable   bool // Served under its hashed name
+}
+
+// assetHandler serves the embedded web UI. CSS and JS files are also
+// served under a content-hashed name ("app.3f2a9c1e.js") that the HTML
+// pages reference, so they can be cached indefinitely while a new binary
+// still gets its new assets picked up immediately.
+type assetHandler struct {
+	assets map[string]*asset // URL path -> asset
+}
+
+// newAssetHandler loads every file of fsys into memory.
+func newAssetHandler(fsys fs.FS) (*assetHandler, error) {
+	h := &assetHandler{
+		assets: map[string]*asset{},
+	}
+
+	files := map[string][]byte{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		files[p] = data
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load web UI: %w", err)
+	}
+
+	// Hash the stylesheets and scripts first, the pages refer to them
+	hashed := map[string]string{} // original name -> hashed name
+	for name, data := range files {
+		ext := path.Ext(name)
+		if ext != ".css" && ext != ".js" {
+			continue
+		}
+		a := newAsset(name, data)
+		hashedName := strings.TrimSuffix(name, ext) + "." + a.etag[1:9] + ext
+		hashed[name] = hashedName
+
+		immutable := *a
+		immutable.immutable = true
+		h.assets["/"+hashedName] = &immutable
+		h.assets["/"+name] = a
+	}
+
+	for name, data := range files {
+		if _, ok := hashed[name]; ok {
+			continue
+		}
+		if path.Ext(name) == ".html" {
+			for original, hashedName := range hashed {
+				data = bytes.ReplaceAll(data, []byte(`"`+original+`"`), []byte(`"`+hashedName+`"`))
+			}
+		}
+		h.assets["/"+name] = newAsset(name, data)
+	}
+
+	if index, ok := h.assets["/index.html"]; ok {
+		h.assets["/"] = index
+	}
+
+	return h, nil
+}
+
+func newAsset(name string, data []byte) *asset {
+	sum := sha256.Sum256(data)
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return &asset{
+		name:        name,
+		content:     data,
+		contentType: contentType,
+		etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+}
+
+// canonicalPath maps a hashed asset URL back to its original path, so
+// access rules can be written against the names in the static directory.
+func (h *assetHandler) canonicalPath(urlPath string) string {
+	if a, ok := h.assets[urlPath]; ok && a.immutable {
+		return "/" + a.name
+	}
+	return urlPath
+}
+
+func (h *assetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	a, ok := h.assets[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", a.contentType)
+	w.Header().Set("ETag", a.etag)
+	if a.immutable {
+		w.Header().Set("Cache-Control", immutableCache)
+	} else {
+		w.Header().Set("Cache-Control", revalidateCache)
+	}
+
+	// ServeContent answers If-None-Match with 304 and handles Range requests.
+	// Embedded files have no modification time, the ETag is all we need.
+	http.ServeContent(w, r, a.name, time.Time{}, bytes.NewReader(a.content))
+}
+
+// staticHandler returns the handler for the web UI: the embedded copy, or
+// the files in StaticDir when set (for frontend development, so edits show
+// up on reload without rebuilding).
+func (s *Server) staticHandler() (http.Handler, error) {
+	if s.opts.StaticDir != "" {
+		slog.Info("Serving web UI from disk", "dir", s.opts.StaticDir)
+		files := http.FileServer(http.Dir(s.opts.StaticDir))
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", revalidateCache)
+			files.ServeHTTP(w, r)
+		}), nil
+	}
+
+	h, err := newAssetHandler(static.FS)
+	if err != nil {
+		return nil, err
+	}
+	s.assets = h
+	return h, nil
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"telegram-manager/internal/audit"
+	"telegram-manager/internal/tg"
+)
+
+// defaultAuditLimit is the default of the limit parameter of /audit, which
+// is capped at audit.MaxRead.
+const defaultAuditLimit = 100
+
+// AuditResponse is returned by /audit.
+type AuditResponse struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+// audit records a mutating action made through the API, with err as its
+// outcome.
+func (s *Server) audit(r *http.Request, client *tg.Client, action string, ids []int, err error) {
+	s.opts.Audit.Record(audit.Entry{
+		Action:     action,
+		Account:    client.Account,
+		Source:     audit.SourceAPI,
+		ClientIP:   clientIP(r),
+		MessageIDs: ids,
+	}, err)
+}
+
+// sentIDs is the message ID list to audit for a new message, empty if
+// sending failed.
+func sentIDs(id int) []int {
+	if id == 0 {
+		return nil
+	}
+	return []int{id}
+}
+
+// clientIP returns the address of the HTTP client without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (s *Server) handleGetAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	if !s.opts.Audit.Enabled() {
+		writeError(w, http.StatusNotFound, codeNotFound, "Audit log is disabled")
+		return
+	}
+
+	limit, err := queryInt(r, "limit")
+	if err != nil || limit < 0 {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid limit")
+		return
+	}
+	if limit == 0 {
+		limit = defaultAuditLimit
+	}
+
+	filter := audit.Filter{
+		Account: r.URL.Query().Get("account"),
+		Action:  r.URL.Query().Get("action"),
+	}
+
+	entries, err := s.opts.Audit.Read(filter, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternalError, "Failed to read audit log")
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+
+	writeJSON(w, http.StatusOK, AuditResponse{Entries: entries})
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookie = "session"
+	csrfCookie    = "csrf_token"
+	csrfHeader    = "X-CSRF-Token"
+
+	sessionTTL = 7 * 24 * time.Hour
+
+	// loginFailureDelay slows down password guessing.
+	loginFailureDelay = time.Second
+)
+
+// publicPaths are reachable without being logged in.
+var publicPaths = map[string]bool{
+	"/login.html":               true,
+	"/style.css":                true,
+	legacyAPIPrefix + "/login":  true,
+	apiPrefix + "/login":        true,
+	apiPrefix + "/openapi.json": true,
+	"/healthz":                  true,
+	"/readyz":                   true,
+}
+
+// sessionStore keeps logged-in browser sessions in memory.
+// Sessions do not survive a restart, users simply log in again.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]time.Time // session ID -> expiry
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: map[string]time.Time{}}
+}
+
+func (st *sessionStore) create() (string, error) {
+	id, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	// Drop expired sessions while we hold the lock anyway
+	now := time.Now()
+	for sid, expiry := range st.sessions {
+		if now.After(expiry) {
+			delete(st.sessions, sid)
+		}
+	}
+
+	st.sessions[id] = now.Add(sessionTTL)
+	return id, nil
+}
+
+func (st *sessionStore) valid(id string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	expiry, ok := st.sessions[id]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiry) {
+		delete(st.sessions, id)
+		return false
+	}
+	return true
+}
+
+func (st *sessionStore) delete(id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.sessions, id)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// authEnabled reports whether a password is configured.
+func (s *Server) authEnabled() bool {
+	return s.opts.Password != ""
+}
+
+// checkPassword compares a candidate with the configured password in constant time.
+func (s *Server) checkPassword(candidate string) bool {
+	return subtle.ConstantTimeCompare([]byte(candidate), []byte(s.opts.Password)) == 1
+}
+
+// authenticated reports whether the request carries a valid session cookie
+// or a valid "Authorization: Bearer <password>" header.
+func (s *Server) authenticated(r *http.Request) bool {
+	if !s.authEnabled() {
+		return true
+	}
+
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return s.checkPassword(token)
+	}
+
+	c, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+	return s.sessions.valid(c.Value)
+}
+
+// requireAuth rejects unauthenticated requests: API calls get a 401,
+// page loads are redirected to the login page.
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		urlPath := r.URL.Path
+		if s.assets != nil {
+			urlPath = s.assets.canonicalPath(urlPath)
+		}
+		if publicPaths[urlPath] || s.authenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Scrapers would follow the redirect and choke on the login page
+		if isAPIPath(r.URL.Path) || r.URL.Path == "/metrics" {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "Unauthorized")
+			return
+		}
+		http.Redirect(w, r, "/login.html", http.StatusSeeOther)
+	})
+}
+
+// csrfProtect implements the double-submit cookie pattern: every response
+// makes sure the browser has a csrf_token cookie, and every mutating request
+// must echo it back in the X-CSRF-Token header. Other origins can neither
+// read the cookie nor set the header without a CORS preflight.
+// Bearer-authenticated requests carry no ambient credentials and are exempt.
+func (s *Server) csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(csrfCookie)
+		if err != nil || cookie.Value == "" {
+			token, err := randomToken()
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, codeInternalError, "Internal error")
+				return
+			}
+			cookie = &http.Cookie{Name: csrfCookie, Value: token}
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookie,
+				Value:    token,
+				Path:     "/",
+				SameSite: http.SameSiteStrictMode,
+				Secure:   r.TLS != nil,
+			})
+		}
+
+		safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		exempt := r.URL.Path == legacyAPIPrefix+"/login" || r.URL.Path == apiPrefix+"/login" || strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !safe && !exempt {
+			header := r.Header.Get(csrfHeader)
+			if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+				slog.Warn("Rejected request with missing or invalid CSRF token", "method", r.Method, "path", r.URL.Path, "client_ip", clientIP(r))
+				writeError(w, http.StatusForbidden, codeForbidden, "Invalid CSRF token")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LoginForm documents the form posted by the login page.
+type LoginForm struct {
+	Password string `json:"password"`
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	if !s.authEnabled() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	if !s.checkPassword(r.FormValue("password")) {
+		slog.Warn("Failed login attempt", "client_ip", clientIP(r))
+		time.Sleep(loginFailureDelay)
+		http.Redirect(w, r, "/login.html?error=1", http.StatusSeeOther)
+		return
+	}
+
+	id, err := s.sessions.create()
+	if err != nil {
+		slog.Error("Failed to create session", "err", err)
+		writeError(w, http.StatusInternalServerError, codeInternalError, "Failed to create session")
+		return
+	}
+
+	slog.Info("Login", "client_ip", clientIP(r))
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(sessionTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		s.sessions.delete(c.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type SessionResponse struct {
+	AuthEnabled bool `json:"auth_enabled"`
+}
+
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SessionResponse{AuthEnabled: s.authEnabled()})
+}
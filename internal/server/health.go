@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+
+	"telegram-manager/internal/tg"
+)
+
+// HealthResponse is returned by /healthz and /readyz.
+type HealthResponse struct {
+	Status string `json:"status"` // "ok", "ready" or "not_ready"
+	// Accounts is only included for authenticated requests, the probes
+	// themselves are public.
+	Accounts []AccountStatus `json:"accounts,omitempty"`
+}
+
+// AccountStatus is the connection state of one account.
+type AccountStatus struct {
+	Name   string    `json:"name"`
+	Status tg.Status `json:"status"`
+}
+
+// handleHealthz is the liveness probe: the process is up and serving HTTP.
+// Telegram connection problems do not make it fail, restarting would not help.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// handleReadyz is the readiness probe: it fails with 503 until every
+// account is connected and logged in.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	response := HealthResponse{Status: "ready"}
+	status := http.StatusOK
+	for _, c := range s.accounts {
+		if !c.Ready() {
+			response.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+		if s.authenticated(r) {
+			response.Accounts = append(response.Accounts, AccountStatus{Name: c.Account, Status: c.Status()})
+		}
+	}
+
+	writeJSON(w, status, response)
+}
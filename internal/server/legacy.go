@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// legacyAPI keeps the responses of the unversioned /api paths as they were
+// before /api/v1: errors are plain text instead of the JSON envelope, and
+// requests without a response body answer 200 instead of 204. Everything
+// else is shared with /api/v1.
+func legacyAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, legacyAPIPrefix+"/") || strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		lw := &legacyWriter{ResponseWriter: w}
+		next.ServeHTTP(lw, r)
+		lw.finish()
+	})
+}
+
+// legacyWriter rewrites responses for legacyAPI. The body of a JSON error
+// is held back until the handler is done, then sent as its message alone.
+type legacyWriter struct {
+	http.ResponseWriter
+	errStatus int // Status of a held back error, 0 if none
+	errBody   bytes.Buffer
+}
+
+func (w *legacyWriter) WriteHeader(status int) {
+	switch {
+	case status == http.StatusNoContent:
+		status = http.StatusOK
+	case status >= 400 && w.Header().Get("Content-Type") == "application/json":
+		w.errStatus = status
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *legacyWriter) Write(p []byte) (int, error) {
+	if w.errStatus != 0 {
+		return w.errBody.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (w *legacyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish sends a held back error as plain text, like http.Error did.
+func (w *legacyWriter) finish() {
+	if w.errStatus == 0 {
+		return
+	}
+
+	message := http.StatusText(w.errStatus)
+	var resp ErrorResponse
+	if err := json.Unmarshal(w.errBody.Bytes(), &resp); err == nil && resp.Error.Message != "" {
+		message = resp.Error.Message
+	}
+	http.Error(w.ResponseWriter, message, w.errStatus)
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLegacyAPI(t *testing.T) {
+	handler := legacyAPI(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("case") {
+		case "error":
+			writeError(w, http.StatusBadRequest, codeBadRequest, "id required")
+		case "empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJSON(w, http.StatusOK, map[string]int{"id": 1})
+		}
+	}))
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/api/edit?case=error", http.StatusBadRequest, "text/plain; charset=utf-8", "id required\n"},
+		{"/api/edit?case=empty", http.StatusOK, "", ""},
+		{"/api/send", http.StatusOK, "application/json", `{"id":1}` + "\n"},
+		{"/api/v1/edit?case=error", http.StatusBadRequest, "application/json", `"code":"bad_request"`},
+		{"/api/v1/edit?case=empty", http.StatusNoContent, "", ""},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+		if rec.Code != tt.status || rec.Header().Get("Content-Type") != tt.contentType || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s: got %d %q %q, want %d %q %q", tt.path, rec.Code, rec.Header().Get("Content-Type"), rec.Body, tt.status, tt.contentType, tt.body)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"telegram-manager/internal/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec("telegram_manager_http_requests_total",
+		"API requests by route, method and status code.", "route", "method", "code")
+	httpDuration = metrics.NewHistogramVec("telegram_manager_http_request_duration_seconds",
+		"Latency of API requests by route.", metrics.DefaultBuckets, "route", "method")
+)
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController, so handlers
+// can still flush or set deadlines.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// methodLabel returns the method of r for the metric labels. Clients can
+// send any token as the method, so non-standard ones count as "OTHER".
+func methodLabel(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return r.Method
+	}
+	return "OTHER"
+}
+
+// instrument records requests and latency of an API route. The route is
+// labelled with its path in the route table, so the legacy and versioned
+// prefixes count together and query strings cannot blow up the series.
+func instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next(rec, r)
+
+		method := methodLabel(r)
+		httpRequests.Inc(route, method, strconv.Itoa(rec.status))
+		httpDuration.Observe(time.Since(start).Seconds(), route, method)
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	handler := instrument("/test", func(w http.ResponseWriter, r *http.Request) {
+		// Flushing must reach the real writer through the recorder
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("flush failed: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	for _, method := range []string{http.MethodGet, "BREW", "PROPFIND"} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, "/test", nil))
+		if !rec.Flushed {
+			t.Errorf("%s: response was not flushed", method)
+		}
+	}
+
+	if got := httpRequests.Value("/test", http.MethodGet, "202"); got != 1 {
+		t.Errorf("GET requests = %v, want 1", got)
+	}
+	if got := httpRequests.Value("/test", "OTHER", "202"); got != 2 {
+		t.Errorf("OTHER requests = %v, want 2", got)
+	}
+}
@@ -3,156 +3,336 @@ package server
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"telegram-manager/internal/audit"
+	"telegram-manager/internal/export"
+	"telegram-manager/internal/metrics"
 	"telegram-manager/internal/tg"
+	"time"
 )
 
+// Options configure the HTTP server
+type Options struct {
+	// Host is the interface to listen on, e.g. "127.0.0.1". Empty means all interfaces.
+	Host string
+	// Password protects the UI and API. Empty disables authentication.
+	Password string
+	// StaticDir serves the web UI from disk instead of the copy embedded in
+	// the binary. Meant for frontend development.
+	StaticDir string
+	// MediaMaxAge lets browsers cache /api/media responses for this long.
+	MediaMaxAge time.Duration
+
+	// TLSCertFile and TLSKeyFile enable HTTPS with a user-provided certificate.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSSelfSigned enables HTTPS with a self-signed certificate when no
+	// files are given. The certificate is generated once and kept in TLSDir
+	// (default "tls") so browsers only have to trust it once.
+	TLSSelfSigned bool
+	TLSDir        string
+	// RedirectPort, when set and TLS is enabled, starts a plain HTTP
+	// listener on that port which redirects every request to HTTPS.
+	RedirectPort string
+
+	// Audit records deletions and other changes. Nil disables auditing.
+	Audit *audit.Log
+}
+
 // Server holds dependencies for the HTTP server
 type Server struct {
-	tgClient *tg.Client
+	accounts []*tg.Client // The first one is the default account
+	opts     Options
+	sessions *sessionStore
+	assets   *assetHandler // Nil when serving from StaticDir
 }
 
-// NewServer creates a new HTTP server
-func NewServer(tgClient *tg.Client) *Server {
+// NewServer creates a new HTTP server serving one or more accounts.
+// The first account is used when a request does not name one.
+func NewServer(accounts []*tg.Client, opts Options) *Server {
 	return &Server{
-		tgClient: tgClient,
+		accounts: accounts,
+		opts:     opts,
+		sessions: newSessionStore(),
 	}
 }
 
 // Start starts the HTTP server on the given port
 func (s *Server) Start(ctx context.Context, port string) error {
+	static, err := s.staticHandler()
+	if err != nil {
+		return err
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle("/", http.FileServer(http.Dir("./static")))
-	mux.HandleFunc("/api/messages", s.handleGetMessages)
-	mux.HandleFunc("/api/delete", s.handleDeleteMessages)
-	mux.HandleFunc("/api/media", s.handleGetMedia)
+	mux.Handle("/", static)
+	s.registerAPI(mux)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	// Behind the password like the API; scrapers send it as a bearer token
+	mux.Handle("/metrics", metrics.Handler())
 
+	addr := net.JoinHostPort(s.opts.Host, port)
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:    addr,
+		Handler: legacyAPI(s.csrfProtect(s.requireAuth(mux))),
+	}
+
+	if !s.authEnabled() && !isLoopback(s.opts.Host) {
+		slog.Warn("Listening without a password; anyone on the network can read and delete your messages", "addr", addr)
 	}
 
 	// Create a channel to catch server start errors
-	serverError := make(chan error, 1)
+	serverError := make(chan error, 2)
 
-	go func() {
-		log.Printf("Server starting on http://localhost:%s", port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			serverError <- err
+	var redirectSrv *http.Server
+	if s.tlsEnabled() {
+		certFile, keyFile, err := s.tlsFiles()
+		if err != nil {
+			return fmt.Errorf("failed to prepare TLS certificate: %w", err)
 		}
-	}()
+
+		go func() {
+			slog.Info("Server starting", "url", "https://"+displayAddr(s.opts.Host, port))
+			if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil && err != http.ErrServerClosed {
+				serverError <- err
+			}
+		}()
+
+		if s.opts.RedirectPort != "" {
+			redirectSrv = &http.Server{
+				Addr:    net.JoinHostPort(s.opts.Host, s.opts.RedirectPort),
+				Handler: redirectHandler(port),
+			}
+			go func() {
+				slog.Info("Redirecting to HTTPS", "url", "http://"+displayAddr(s.opts.Host, s.opts.RedirectPort))
+				if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					serverError <- err
+				}
+			}()
+		}
+	} else {
+		go func() {
+			slog.Info("Server starting", "url", "http://"+displayAddr(s.opts.Host, port))
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serverError <- err
+			}
+		}()
+	}
 
 	// Wait for context cancellation or server error
 	select {
 	case <-ctx.Done():
-		log.Println("Shutting down HTTP server...")
+		slog.Info("Shutting down HTTP server")
+		if redirectSrv != nil {
+			redirectSrv.Shutdown(context.Background())
+		}
 		return srv.Shutdown(context.Background())
 	case err := <-serverError:
+		if redirectSrv != nil {
+			redirectSrv.Close()
+		}
+		srv.Close()
 		return err
 	}
 }
 
+// isLoopback reports whether host only accepts local connections.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// displayAddr returns a browsable address for the listen host and port.
+func displayAddr(host, port string) string {
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
 func (s *Server) handleGetMedia(w http.ResponseWriter, r *http.Request) {
 	// ... existing ...
 	// Just ensure it's kept or I can just use existing logic if I didn't verify lines match perfectly.
 	// I will replace handleGetMedia just to be safe if I'm replacing the block including it.
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
 		return
 	}
 
 	idStr := r.URL.Query().Get("id")
 	if idStr == "" {
-		http.Error(w, "id required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "id required")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "invalid id")
+		return
+	}
+
+	priority, err := tg.ParseMediaPriority(r.URL.Query().Get("priority"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
 		return
 	}
 
-	// Log media access? Maybe too verbose. User asked for activity log.
-	// "Downloading media for ID ..."
-	log.Printf("Activity: Fetching media for message %d", id)
+	// Every thumbnail in the feed is a request, keep it out of the info log
+	slog.Debug("Fetching media", "account", client.Account, "id", id, "priority", priority)
 
-	data, contentType, err := s.tgClient.GetMessageMedia(r.Context(), id)
+	data, contentType, err := client.GetMessageMedia(r.Context(), id, priority)
 	if err != nil {
-		log.Printf("Error fetching media for %d: %v", id, err)
-		http.Error(w, "Failed to get media", http.StatusInternalServerError)
+		slog.Error("Failed to fetch media", "account", client.Account, "id", id, "err", err)
+		writeClientError(w, err, "Failed to get media")
 		return
 	}
 
 	w.Header().Set("Content-Type", contentType)
+	if s.opts.MediaMaxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(s.opts.MediaMaxAge.Seconds())))
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write(data)
 }
 
+type MessagesResponse struct {
+	Messages []tg.SavedMessage `json:"messages"`
+	Total    int               `json:"total"`
+	UserID   int64             `json:"user_id"`
+	// Opaque cursors for the neighbouring pages, empty at either end
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
 func (s *Server) handleGetMessages(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
 		return
 	}
 
-	offsetIDStr := r.URL.Query().Get("offset_id")
-	limitStr := r.URL.Query().Get("limit")
-	addOffsetStr := r.URL.Query().Get("add_offset")
+	query := r.URL.Query()
 
-	offsetID := 0
-	if offsetIDStr != "" {
+	limit := 0 // Let the client apply the configured default page size
+	if limitStr := query.Get("limit"); limitStr != "" {
 		var err error
-		offsetID, err = strconv.Atoi(offsetIDStr)
+		limit, err = strconv.Atoi(limitStr)
 		if err != nil {
-			http.Error(w, "Invalid offset_id", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid limit")
 			return
 		}
 	}
 
-	limit := 20
-	if limitStr != "" {
+	var userID int64
+	if u := client.CurrentUser(); u != nil {
+		userID = u.ID
+	}
+
+	// Deprecated offset_id/add_offset paging, kept for old clients
+	if query.Get("cursor") == "" && (query.Has("offset_id") || query.Has("add_offset")) {
+		s.getMessagesByOffset(w, r, client, limit, userID)
+		return
+	}
+
+	var cursor tg.Cursor
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
 		var err error
-		limit, err = strconv.Atoi(limitStr)
+		cursor, err = tg.ParseCursor(cursorStr)
 		if err != nil {
-			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid cursor")
 			return
 		}
-	}
-
-	addOffset := 0
-	if addOffsetStr != "" {
-		var err error
-		addOffset, err = strconv.Atoi(addOffsetStr)
+	} else {
+		order, err := tg.ParseOrder(query.Get("order"))
 		if err != nil {
-			http.Error(w, "Invalid add_offset", http.StatusBadRequest)
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid order")
 			return
 		}
+		cursor.Order = order
+
+		if dateStr := query.Get("date"); dateStr != "" {
+			cursor.OffsetDate, err = strconv.Atoi(dateStr)
+			if err != nil || cursor.OffsetDate < 0 {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid date")
+				return
+			}
+		}
 	}
 
-	log.Printf("Activity: Fetching messages (Limit: %d, Offset: %d, AddOffset: %d)", limit, offsetID, addOffset)
+	slog.Info("Fetching messages", "account", client.Account, "limit", limit, "order", cursor.Order, "offset_id", cursor.OffsetID, "backward", cursor.Backward)
 
-	messages, total, err := s.tgClient.GetSavedMessages(r.Context(), offsetID, limit, addOffset)
+	page, err := client.GetMessagePage(r.Context(), cursor, limit)
 	if err != nil {
-		log.Printf("Error fetching messages: %v", err)
-		http.Error(w, "Failed to fetch messages", http.StatusInternalServerError)
+		slog.Error("Failed to fetch messages", "account", client.Account, "err", err)
+		writeClientError(w, err, "Failed to fetch messages")
 		return
 	}
 
-	var userID int64
-	if s.tgClient.User != nil {
-		userID = s.tgClient.User.ID
+	writeJSON(w, http.StatusOK, MessagesResponse{
+		Messages:   page.Messages,
+		Total:      page.Total,
+		UserID:     userID,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	})
+}
+
+// getMessagesByOffset serves the old offset_id/add_offset paging.
+func (s *Server) getMessagesByOffset(w http.ResponseWriter, r *http.Request, client *tg.Client, limit int, userID int64) {
+	offsetID, err := queryInt(r, "offset_id")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid offset_id")
+		return
 	}
 
-	response := map[string]interface{}{
-		"messages": messages,
-		"total":    total,
-		"user_id":  userID,
+	addOffset, err := queryInt(r, "add_offset")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid add_offset")
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	slog.Info("Fetching messages", "account", client.Account, "limit", limit, "offset_id", offsetID, "add_offset", addOffset)
+
+	messages, total, err := client.GetSavedMessages(r.Context(), offsetID, limit, addOffset)
+	if err != nil {
+		slog.Error("Failed to fetch messages", "account", client.Account, "err", err)
+		writeClientError(w, err, "Failed to fetch messages")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MessagesResponse{
+		Messages: messages,
+		Total:    total,
+		UserID:   userID,
+	})
+}
+
+// queryInt parses an optional integer query parameter, 0 if absent.
+func queryInt(r *http.Request, name string) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
 }
 
 type DeleteRequest struct {
@@ -161,21 +341,472 @@ type DeleteRequest struct {
 
 func (s *Server) handleDeleteMessages(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
 		return
 	}
 
 	var req DeleteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid request body")
 		return
 	}
 
-	if err := s.tgClient.DeleteMessages(r.Context(), req.IDs); err != nil {
-		log.Printf("Error deleting messages: %v", err)
-		http.Error(w, "Failed to delete messages", http.StatusInternalServerError)
+	slog.Info("Deleting messages", "account", client.Account, "ids", req.IDs)
+
+	err := client.DeleteMessages(r.Context(), req.IDs)
+	s.audit(r, client, audit.ActionDelete, req.IDs, err)
+	if err != nil {
+		slog.Error("Failed to delete messages", "account", client.Account, "ids", req.IDs, "err", err)
+		writeClientError(w, err, "Failed to delete messages")
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type SendRequest struct {
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode"` // "" (plain) or "html"
+}
+
+type SendResponse struct {
+	ID int `json:"id"`
+}
+
+func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	var req SendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid request body")
+		return
+	}
+
+	if strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "text required")
+		return
+	}
+
+	parseMode, ok := parseModeOf(w, req.ParseMode)
+	if !ok {
+		return
+	}
+
+	slog.Info("Sending new note", "account", client.Account, "chars", len(req.Text))
+
+	id, err := client.SendText(r.Context(), req.Text, parseMode)
+	s.audit(r, client, audit.ActionSend, sentIDs(id), err)
+	if err != nil {
+		slog.Error("Failed to send message", "account", client.Account, "err", err)
+		writeClientError(w, err, "Failed to send message")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SendResponse{ID: id})
+}
+
+// parseModeOf validates the parse_mode of a request. It responds with 400
+// for unknown modes, which would otherwise fail deep in the client.
+func parseModeOf(w http.ResponseWriter, mode string) (tg.ParseMode, bool) {
+	switch m := tg.ParseMode(mode); m {
+	case tg.ParseModePlain, tg.ParseModeHTML:
+		return m, true
+	}
+	writeError(w, http.StatusBadRequest, codeBadRequest, `parse_mode must be "" or "html"`)
+	return "", false
+}
+
+// maxUploadMemory is how much of a multipart upload is kept in memory;
+// the rest is spooled to temporary files by net/http.
+const maxUploadMemory = 32 << 20
+
+// maxUploadBytes bounds upload request bodies: Telegram's 2000 MiB file
+// limit, plus room for the caption and multipart framing. Without it a
+// client could fill the temporary directory.
+const maxUploadBytes = 2000<<20 + 1<<20
+
+// UploadForm documents the multipart form accepted by /upload.
+type UploadForm struct {
+	File      string `json:"file" format:"binary"`
+	Caption   string `json:"caption"`
+	ParseMode string `json:"parse_mode"` // "" (plain) or "html"
+}
+
+func (s *Server) handleUploadFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, codeTooLarge, "File exceeds Telegram's 2000 MiB upload limit")
+			return
+		}
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "file required")
+		return
+	}
+	defer file.Close()
+
+	caption := r.FormValue("caption")
+	parseMode, ok := parseModeOf(w, r.FormValue("parse_mode"))
+	if !ok {
+		return
+	}
+	mimeType := header.Header.Get("Content-Type")
+
+	slog.Info("Uploading file", "account", client.Account, "filename", header.Filename, "bytes", header.Size, "mime_type", mimeType)
+
+	id, err := client.SendFile(r.Context(), header.Filename, mimeType, file, header.Size, caption, parseMode)
+	s.audit(r, client, audit.ActionUpload, sentIDs(id), err)
+	if err != nil {
+		slog.Error("Failed to upload file", "account", client.Account, "filename", header.Filename, "err", err)
+		writeClientError(w, err, "Failed to upload file")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SendResponse{ID: id})
+}
+
+type EditRequest struct {
+	ID        int    `json:"id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode"` // "" (plain) or "html"
+}
+
+func (s *Server) handleEditMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	var req EditRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.ID == 0 {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "id required")
+		return
+	}
+
+	parseMode, ok := parseModeOf(w, req.ParseMode)
+	if !ok {
+		return
+	}
+
+	slog.Info("Editing message", "account", client.Account, "id", req.ID)
+
+	err := client.EditMessage(r.Context(), req.ID, req.Text, parseMode)
+	s.audit(r, client, audit.ActionEdit, []int{req.ID}, err)
+	if err != nil {
+		slog.Error("Failed to edit message", "account", client.Account, "id", req.ID, "err", err)
+		writeClientError(w, err, "Failed to edit message")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type PinnedResponse struct {
+	Messages []tg.SavedMessage `json:"messages"`
+}
+
+func (s *Server) handleGetPinned(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	slog.Info("Fetching pinned messages", "account", client.Account)
+
+	messages, err := client.GetPinnedMessages(r.Context())
+	if err != nil {
+		slog.Error("Failed to fetch pinned messages", "account", client.Account, "err", err)
+		writeClientError(w, err, "Failed to fetch pinned messages")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PinnedResponse{Messages: messages})
+}
+
+type PinRequest struct {
+	ID     int  `json:"id"`
+	Pinned bool `json:"pinned"`
+}
+
+func (s *Server) handlePinMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	var req PinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.ID == 0 {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "id required")
+		return
+	}
+
+	slog.Info("Setting pin", "account", client.Account, "id", req.ID, "pinned", req.Pinned)
+
+	action := audit.ActionPin
+	if !req.Pinned {
+		action = audit.ActionUnpin
+	}
+
+	err := client.SetPinned(r.Context(), req.ID, req.Pinned)
+	s.audit(r, client, action, []int{req.ID}, err)
+	if err != nil {
+		slog.Error("Failed to update pin", "account", client.Account, "id", req.ID, "err", err)
+		writeClientError(w, err, "Failed to update pin")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type LinksResponse struct {
+	Links   []tg.Link        `json:"links"`
+	Domains []tg.DomainFacet `json:"domains"`
+	Total   int              `json:"total"` // Before filtering
+}
+
+func (s *Server) handleGetLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	// The original download form, kept for existing bookmarks and scripts
+	// but left out of the OpenAPI document in favour of /links/export
+	if r.URL.Query().Get("format") != "" {
+		s.handleExportLinks(w, r)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	domain := r.URL.Query().Get("domain")
+	query := r.URL.Query().Get("q")
+
+	slog.Info("Building link library", "account", client.Account, "domain", domain, "query", query)
+
+	links, err := client.GetLinks(r.Context())
+	if err != nil {
+		slog.Error("Failed to build link library", "account", client.Account, "err", err)
+		writeClientError(w, err, "Failed to build link library")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, LinksResponse{
+		Links: filterLinks(links, domain, query),
+		// Facets are computed over all links so the UI can switch domains freely
+		Domains: tg.LinkDomains(links),
+		Total:   len(links),
+	})
+}
+
+func (s *Server) handleExportLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	formatStr := r.URL.Query().Get("format")
+	if formatStr == "" {
+		formatStr = string(export.FormatBookmarks)
+	}
+	format, err := export.ParseFormat(formatStr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid format")
+		return
+	}
+
+	domain := r.URL.Query().Get("domain")
+	query := r.URL.Query().Get("q")
+
+	slog.Info("Exporting links", "account", client.Account, "format", format, "domain", domain, "query", query)
+
+	links, err := client.GetLinks(r.Context())
+	if err != nil {
+		slog.Error("Failed to build link library", "account", client.Account, "err", err)
+		writeClientError(w, err, "Failed to build link library")
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="saved-links.%s"`, format.Extension()))
+	if err := export.WriteLinks(w, format, filterLinks(links, domain, query)); err != nil {
+		slog.Error("Failed to write links export", "account", client.Account, "err", err)
+	}
+}
+
+// filterLinks keeps links from the given domain whose URL or title contains
+// query (case-insensitive). Empty arguments match everything.
+func filterLinks(links []tg.Link, domain, query string) []tg.Link {
+	query = strings.ToLower(query)
+
+	filtered := []tg.Link{}
+	for _, link := range links {
+		if domain != "" && link.Domain != domain {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(link.URL), query) && !strings.Contains(strings.ToLower(link.Title), query) {
+			continue
+		}
+		filtered = append(filtered, link)
+	}
+
+	return filtered
+}
+
+func (s *Server) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	top := 20
+	if topStr := r.URL.Query().Get("top"); topStr != "" {
+		var err error
+		top, err = strconv.Atoi(topStr)
+		if err != nil || top < 0 {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid top")
+			return
+		}
+	}
+
+	slog.Info("Computing storage stats", "account", client.Account, "top", top)
+
+	stats, err := client.GetStats(r.Context(), top)
+	if err != nil {
+		slog.Error("Failed to compute stats", "account", client.Account, "err", err)
+		writeClientError(w, err, "Failed to compute stats")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// accountHeader selects the account an API call operates on. Requests that
+// cannot set headers (e.g. <img src>) use the "account" query parameter.
+const accountHeader = "X-Account"
+
+// clientFor returns the Telegram client of the account named by the request,
+// or the default account. It responds with 404 for unknown accounts.
+func (s *Server) clientFor(w http.ResponseWriter, r *http.Request) (*tg.Client, bool) {
+	name := r.Header.Get(accountHeader)
+	if name == "" {
+		name = r.URL.Query().Get("account")
+	}
+
+	if name == "" && len(s.accounts) > 0 {
+		return s.accounts[0], true
+	}
+
+	for _, c := range s.accounts {
+		if c.Account == name {
+			return c, true
+		}
+	}
+
+	writeError(w, http.StatusNotFound, codeNotFound, "Unknown account")
+	return nil, false
+}
+
+type AccountInfo struct {
+	Name      string    `json:"name"`
+	Ready     bool      `json:"ready"`
+	Status    tg.Status `json:"status"`
+	UserID    int64     `json:"user_id,omitempty"`
+	FirstName string    `json:"first_name,omitempty"`
+	LastName  string    `json:"last_name,omitempty"`
+	Username  string    `json:"username,omitempty"`
+}
+
+type AccountsResponse struct {
+	Accounts []AccountInfo `json:"accounts"`
+}
+
+func (s *Server) handleGetAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w)
+		return
+	}
+
+	accounts := make([]AccountInfo, 0, len(s.accounts))
+	for _, c := range s.accounts {
+		status := c.Status()
+		info := AccountInfo{Name: c.Account, Ready: status.State == tg.StateReady, Status: status}
+		if u := c.CurrentUser(); u != nil {
+			info.UserID = u.ID
+			info.FirstName = u.FirstName
+			info.LastName = u.LastName
+			info.Username = u.Username
+		}
+		accounts = append(accounts, info)
+	}
+
+	writeJSON(w, http.StatusOK, AccountsResponse{Accounts: accounts})
 }
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telegram-manager/internal/tg"
+)
+
+func TestUnknownParseModeIsBadRequest(t *testing.T) {
+	client, err := tg.NewClient(tg.DefaultAccount, tg.Options{AppID: 1, AppHash: "hash", SessionDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer([]*tg.Client{client}, Options{})
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, _ := mw.CreateFormFile("file", "note.txt")
+	fw.Write([]byte("hello"))
+	mw.WriteField("parse_mode", "markdown")
+	mw.Close()
+
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		body        *bytes.Buffer
+		contentType string
+	}{
+		{"send", s.handleSendMessage, bytes.NewBufferString(`{"text": "hi", "parse_mode": "markdown"}`), "application/json"},
+		{"edit", s.handleEditMessage, bytes.NewBufferString(`{"id": 1, "text": "hi", "parse_mode": "markdown"}`), "application/json"},
+		{"upload", s.handleUploadFile, &form, mw.FormDataContentType()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/"+tt.name, tt.body)
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+
+			if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "parse_mode") {
+				t.Errorf("status = %d, body %s; want 400 about parse_mode", rec.Code, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"telegram-manager/internal/audit"
+	"telegram-manager/internal/tg"
+)
+
+// SessionExportRequest is the body of /telegram-session/export.
+type SessionExportRequest struct {
+	// Passphrase encrypts the exported string, at least 8 characters.
+	Passphrase string `json:"passphrase"`
+	// Password is the web UI password, required when one is set. Being
+	// logged in is not enough, so a script injected into the page cannot
+	// take the session with the browser's cookies.
+	Password string `json:"password,omitempty"`
+}
+
+// SessionExportResponse is returned by /telegram-session/export.
+type SessionExportResponse struct {
+	Session string `json:"session"`
+}
+
+// SessionImportRequest is the body of /telegram-session/import.
+type SessionImportRequest struct {
+	Session    string `json:"session"`
+	Passphrase string `json:"passphrase"`
+	// Replace overwrites an existing session of the account.
+	Replace bool `json:"replace,omitempty"`
+}
+
+// sessionTransferAllowed refuses session export and import with 403 when
+// the server is open to the network without a password: a session string
+// grants full access to the Telegram account.
+func (s *Server) sessionTransferAllowed(w http.ResponseWriter) bool {
+	if s.authEnabled() || isLoopback(s.opts.Host) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, codeForbidden, "Session transfer requires a password when listening beyond localhost")
+	return false
+}
+
+func (s *Server) handleExportSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if !s.sessionTransferAllowed(w) {
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	var req SessionExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid request body")
+		return
+	}
+	if s.authEnabled() && !s.checkPassword(req.Password) {
+		slog.Warn("Session export with wrong password", "account", client.Account, "client_ip", clientIP(r))
+		time.Sleep(loginFailureDelay)
+		writeError(w, http.StatusForbidden, codeForbidden, "Session export requires the password")
+		return
+	}
+
+	exported, err := client.ExportSession(r.Context(), req.Passphrase)
+	s.audit(r, client, audit.ActionSessionExport, nil, err)
+	switch {
+	case errors.Is(err, tg.ErrShortPassphrase):
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	case errors.Is(err, tg.ErrNoSession):
+		writeError(w, http.StatusNotFound, codeNotFound, "Account is not logged in")
+		return
+	case err != nil:
+		slog.Error("Failed to export session", "account", client.Account, "err", err)
+		writeError(w, http.StatusInternalServerError, codeInternalError, "Failed to export session")
+		return
+	}
+
+	slog.Info("Exported session", "account", client.Account)
+	writeJSON(w, http.StatusOK, SessionExportResponse{Session: exported})
+}
+
+func (s *Server) handleImportSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w)
+		return
+	}
+	if !s.sessionTransferAllowed(w) {
+		return
+	}
+
+	client, ok := s.clientFor(w, r)
+	if !ok {
+		return
+	}
+
+	var req SessionImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Session == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "session required")
+		return
+	}
+
+	err := client.ImportSession(r.Context(), req.Session, req.Passphrase, req.Replace)
+	s.audit(r, client, audit.ActionSessionImport, nil, err)
+	switch {
+	case errors.Is(err, tg.ErrInvalidSession):
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	case errors.Is(err, tg.ErrSessionExists):
+		writeError(w, http.StatusConflict, codeConflict, "Account already has a session, set replace to overwrite it")
+		return
+	case err != nil:
+		slog.Error("Failed to import session", "account", client.Account, "err", err)
+		writeError(w, http.StatusInternalServerError, codeInternalError, "Failed to import session")
+		return
+	}
+
+	// The running connection still uses the old session (or is waiting for
+	// a login on the terminal); start over with the imported one
+	client.Reconnect()
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"telegram-manager/internal/tg"
+)
+
+func TestSessionTransferRequiresPasswordBeyondLocalhost(t *testing.T) {
+	client, err := tg.NewClient(tg.DefaultAccount, tg.Options{AppID: 1, AppHash: "hash", SessionDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		// want is the status of an export of an account without a session:
+		// 403 when refused, 404 when the request got through.
+		want int
+	}{
+		{"all interfaces", Options{Host: ""}, http.StatusForbidden},
+		{"public address", Options{Host: "192.168.1.10"}, http.StatusForbidden},
+		{"loopback", Options{Host: "127.0.0.1"}, http.StatusNotFound},
+		{"localhost", Options{Host: "localhost"}, http.StatusNotFound},
+		{"password", Options{Host: "0.0.0.0", Password: "secret"}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer([]*tg.Client{client}, tt.opts)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/telegram-session/export", strings.NewReader(`{"passphrase": "long enough", "password": "secret"}`))
+			s.handleExportSession(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("export: status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+
+			// An invalid session string is a 400 once the request got through
+			want := tt.want
+			if want == http.StatusNotFound {
+				want = http.StatusBadRequest
+			}
+			rec = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodPost, "/api/v1/telegram-session/import", strings.NewReader(`{"session": "tgm-session-1:x", "passphrase": "long enough"}`))
+			s.handleImportSession(rec, req)
+			if rec.Code != want {
+				t.Errorf("import: status = %d, want %d: %s", rec.Code, want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestSessionExportRequiresPassword(t *testing.T) {
+	client, err := tg.NewClient(tg.DefaultAccount, tg.Options{AppID: 1, AppHash: "hash", SessionDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer([]*tg.Client{client}, Options{Host: "127.0.0.1", Password: "secret"})
+
+	for _, body := range []string{`{"passphrase": "long enough"}`, `{"passphrase": "long enough", "password": "guess"}`} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/telegram-session/export", strings.NewReader(body))
+		s.handleExportSession(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403: %s", body, rec.Code, rec.Body)
+		}
+	}
+}
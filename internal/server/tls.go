@@ -0,0 +1,148 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	selfSignedCertFile = "cert.pem"
+	selfSignedKeyFile  = "key.pem"
+
+	selfSignedValidity = 365 * 24 * time.Hour
+	// Regenerate the certificate when it expires sooner than this.
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// tlsEnabled reports whether the server should serve HTTPS.
+func (s *Server) tlsEnabled() bool {
+	return (s.opts.TLSCertFile != "" && s.opts.TLSKeyFile != "") || s.opts.TLSSelfSigned
+}
+
+// tlsFiles returns the certificate and key to serve, generating a
+// self-signed pair if no files were configured.
+func (s *Server) tlsFiles() (string, string, error) {
+	if s.opts.TLSCertFile != "" && s.opts.TLSKeyFile != "" {
+		return s.opts.TLSCertFile, s.opts.TLSKeyFile, nil
+	}
+
+	dir := s.opts.TLSDir
+	if dir == "" {
+		dir = "tls"
+	}
+	return ensureSelfSignedCert(dir, s.opts.Host)
+}
+
+// ensureSelfSignedCert makes sure dir holds a valid self-signed certificate
+// and key, generating new ones if they are missing or about to expire.
+// The certificate covers localhost, the loopback addresses, the machine's
+// hostname and host (if set).
+func ensureSelfSignedCert(dir string, host string) (string, string, error) {
+	certFile := filepath.Join(dir, selfSignedCertFile)
+	keyFile := filepath.Join(dir, selfSignedKeyFile)
+
+	if pair, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil && pair.Leaf != nil {
+		if time.Until(pair.Leaf.NotAfter) > selfSignedRenewBefore {
+			return certFile, keyFile, nil
+		}
+		slog.Info("Self-signed certificate expires soon, regenerating", "path", certFile, "expires", pair.Leaf.NotAfter.Format(time.DateOnly))
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Telegram Saved Messages Manager"}, CommonName: "localhost"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+	if host != "" && host != "localhost" {
+		if ip := net.ParseIP(host); ip != nil {
+			if !ip.IsUnspecified() && !ip.IsLoopback() {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			}
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal key: %w", err)
+	}
+
+	if err := writePEM(keyFile, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return "", "", err
+	}
+	if err := writePEM(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return "", "", err
+	}
+
+	slog.Info("Generated self-signed certificate", "path", certFile, "valid_until", template.NotAfter.Format(time.DateOnly))
+
+	return certFile, keyFile, nil
+}
+
+func writePEM(path string, blockType string, der []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// redirectHandler sends plain HTTP requests to the same path on the HTTPS port.
+func redirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		target := "https://" + net.JoinHostPort(host, httpsPort) + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
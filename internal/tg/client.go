@@ -6,86 +6,199 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"strconv"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram"
 	"github.com/gotd/td/telegram/auth"
 	"github.com/gotd/td/telegram/downloader"
 	"github.com/gotd/td/tg"
+
+	"telegram-manager/internal/proxy"
 )
 
 // Client wraps the gotd Telegram client to provide high-level operations
 // for the Saved Messages Manager.
 type Client struct {
+	// Account is the name of the account this client is logged into.
+	Account string
+
+	opts        Options
+	sessionPath string
+	storage     session.Storage
+	mediaCache  *mediaCache
+	media       *mediaScheduler
+	log         *slog.Logger // Tagged with the account
+
+	// mu guards the connection, which is replaced on every reconnect while
+	// HTTP handlers keep using the client. See rpc and Supervise.
+	mu     sync.RWMutex
 	client *telegram.Client
 	api    *tg.Client
-	User   *tg.User
+	user   *tg.User
+	status Status
+	// disconnect ends the current connection, see Reconnect.
+	disconnect context.CancelFunc
+}
+
+// Options configure a Client.
+type Options struct {
+	AppID   int
+	AppHash string
+
+	// SessionDir holds the session files of all accounts.
+	SessionDir string
+	// SessionSecret encrypts the session file at rest; nil stores it in plain JSON.
+	SessionSecret []byte
+
+	// DefaultPageSize and MaxPageSize bound GetSavedMessages requests.
+	DefaultPageSize int
+	MaxPageSize     int
+
+	// MediaCacheBytes bounds the in-memory cache of downloaded media; 0 disables it.
+	MediaCacheBytes int64
+	MediaCacheTTL   time.Duration
+	// MediaWorkers is how many media downloads run at once (default 4).
+	MediaWorkers int
+
+	// Proxy routes the Telegram connection through a SOCKS5, HTTP or
+	// MTProxy proxy; nil connects directly.
+	Proxy *proxy.Proxy
+
+	// NonInteractive makes StartAndListen fail with ErrNotLoggedIn instead
+	// of prompting for a phone number and code on the terminal.
+	NonInteractive bool
+
+	// telegram holds base options of the gotd client, e.g. the DC list and
+	// server keys of the in-process test server. Session storage and
+	// middlewares are always set by the client.
+	telegram telegram.Options
 }
 
-// NewClient creates a new Telegram client.
-// It requires APP_ID and APP_HASH from environment variables.
-func NewClient() (*Client, error) {
-	appID := os.Getenv("TG_APP_ID")
-	appHash := os.Getenv("TG_APP_HASH")
+// ErrNotLoggedIn is returned by StartAndListen in non-interactive mode when
+// the account has no valid session.
+var ErrNotLoggedIn = errors.New("not logged in")
+
+// ErrNotReady is returned by API calls made before the client has connected
+// and logged in.
+var ErrNotReady = errors.New("client not initialized")
 
-	if appID == "" || appHash == "" {
-		return nil, errors.New("TG_APP_ID or TG_APP_HASH environment variables not set")
+// DefaultAccount is the account used when no account is configured.
+// It keeps its session at the historical location session/session.json.
+const DefaultAccount = "default"
+
+// accountNameRe restricts account names to something safe to use as a directory name.
+var accountNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidAccountName reports whether name can be used as an account name.
+func ValidAccountName(name string) bool {
+	return accountNameRe.MatchString(name)
+}
+
+// NewClient creates a new Telegram client for the named account.
+// Each account has its own session file: <SessionDir>/<account>/session.json
+// (<SessionDir>/session.json for DefaultAccount).
+func NewClient(account string, opts Options) (*Client, error) {
+	if opts.AppID == 0 || opts.AppHash == "" {
+		return nil, errors.New("telegram app ID or app hash not set")
 	}
 
-	// We use a custom session storage or default file storage "session.json"
-	// For simplicity, we'll let gotd handle the storage in the current directory.
+	if !ValidAccountName(account) {
+		return nil, fmt.Errorf("invalid account name %q: use letters, digits, '-' and '_'", account)
+	}
 
-	// Note: We are not initializing the client connection here fully,
-	// just setting up the struct. The actual connection happens in Run.
-	// However, to make it usable as a service, we often want to wrap the client lifecycle.
+	if opts.SessionDir == "" {
+		opts.SessionDir = "session"
+	}
+	if opts.MaxPageSize <= 0 || opts.MaxPageSize > 100 {
+		opts.MaxPageSize = 100
+	}
+	if opts.DefaultPageSize <= 0 || opts.DefaultPageSize > opts.MaxPageSize {
+		opts.DefaultPageSize = min(20, opts.MaxPageSize)
+	}
 
-	// For this simple app, we will expose a Run method that starts the client
-	// and keeps it running, or we can use the library's recommended pattern.
+	sessionPath := filepath.Join(opts.SessionDir, account, "session.json")
+	if account == DefaultAccount {
+		sessionPath = filepath.Join(opts.SessionDir, "session.json")
+	}
+
+	// Note: We are not initializing the client connection here fully,
+	// just setting up the struct. The actual connection happens in StartAndListen.
+
+	c := &Client{
+		Account:     account,
+		opts:        opts,
+		sessionPath: sessionPath,
+		storage:     newSessionStorage(sessionPath, opts.SessionSecret),
+		mediaCache:  newMediaCache(opts.MediaCacheBytes, opts.MediaCacheTTL),
+		log:         slog.Default().With("account", account),
+		status:      Status{State: StateConnecting, Since: time.Now()},
+	}
+	c.media = newMediaScheduler(c, opts.MediaWorkers)
 
-	return &Client{}, nil // Real initialization happens in Start
+	return c, nil // Real initialization happens in Start
 }
 
 // StartAndListen connects to Telegram and blocks.
 // It executes the 'onReady' callback when the client is authenticated and ready to query.
+// It returns when the connection ends; use Supervise to keep reconnecting.
 func (c *Client) StartAndListen(ctx context.Context, onReady func(ctx context.Context) error) error {
-	appID := os.Getenv("TG_APP_ID")
-	appHash := os.Getenv("TG_APP_HASH")
-	if appID == "" || appHash == "" {
-		return errors.New("missing TG_APP_ID/TG_APP_HASH")
-	}
-
 	// Basic session file
-	sessionDir := "session"
-	if err := os.MkdirAll(sessionDir, 0700); err != nil {
+	if err := os.MkdirAll(filepath.Dir(c.sessionPath), 0700); err != nil {
 		return err
 	}
 
-	appIDInt, err := strconv.Atoi(appID)
-	if err != nil {
-		return fmt.Errorf("invalid TG_APP_ID: %w", err)
+	if c.opts.Proxy != nil {
+		c.log.Info("Connecting through proxy", "proxy", c.opts.Proxy.String())
 	}
 
-	client := telegram.NewClient(appIDInt, appHash, telegram.Options{
-		SessionStorage: &telegram.FileSessionStorage{
-			Path: "session/session.json",
-		},
-	})
+	for {
+		c.setState(StateConnecting, nil)
+
+		// A fresh gotd client per connection: a client whose Run returned
+		// cannot be run again.
+		tgOpts := c.opts.telegram
+		tgOpts.SessionStorage = c.sessionStorage()
+		if c.opts.Proxy != nil {
+			tgOpts.Resolver = c.opts.Proxy.Resolver()
+		}
+		tgOpts.Middlewares = append([]telegram.Middleware{c.metricsMiddleware()}, tgOpts.Middlewares...)
+		client := telegram.NewClient(c.opts.AppID, c.opts.AppHash, tgOpts)
+		c.setConnection(client, nil, c.CurrentUser())
 
-	c.client = client
+		runCtx, disconnect := context.WithCancel(ctx)
+		c.mu.Lock()
+		c.disconnect = disconnect
+		c.mu.Unlock()
 
-	for {
-		err := client.Run(ctx, func(ctx context.Context) error {
+		err := client.Run(runCtx, func(ctx context.Context) error {
 			// Auth flow
 			status, err := client.Auth().Status(ctx)
 			if err != nil {
 				return fmt.Errorf("auth status error: %w", err)
 			}
 
+			if !status.Authorized && c.opts.NonInteractive {
+				return ErrNotLoggedIn
+			}
+
 			if !status.Authorized {
-				flow := auth.NewFlow(termAuth{}, auth.SendCodeOptions{})
-				if err := client.Auth().IfNecessary(ctx, flow); err != nil {
+				c.setState(StateAuthorizing, nil)
+
+				// Several accounts may need to log in at once; only one
+				// of them can talk to the terminal at a time.
+				termAuthMu.Lock()
+				flow := auth.NewFlow(termAuth{account: c.Account}, auth.SendCodeOptions{})
+				err := client.Auth().IfNecessary(ctx, flow)
+				termAuthMu.Unlock()
+				if err != nil {
 					return fmt.Errorf("auth error: %w", err)
 				}
 			}
@@ -95,64 +208,47 @@ func (c *Client) StartAndListen(ctx context.Context, onReady func(ctx context.Co
 			if err != nil {
 				return fmt.Errorf("failed to get self: %w", err)
 			}
-			c.User = self
-			c.api = client.API()
+			c.setConnection(client, client.API(), self)
+			c.setState(StateReady, nil)
 
-			fmt.Printf("Logged in as %s %s (@%s)\n", self.FirstName, self.LastName, self.Username)
+			// Logged to stderr so CLI output on stdout stays machine-readable
+			c.log.Info("Logged in", "first_name", self.FirstName, "last_name", self.LastName, "username", self.Username)
 
 			return onReady(ctx)
 		})
 
-		if err != nil {
-			if strings.Contains(err.Error(), "AUTH_RESTART") { // Check for AUTH_RESTART error
-				fmt.Println("Received AUTH_RESTART. Deleting session and restarting...")
-				// Delete session file to force re-auth
-				if rErr := os.RemoveAll("session"); rErr != nil {
-					fmt.Printf("failed to remove session directory: %v\n", rErr)
-				}
-				// Recreate client is a bit tricky here because we created it outside loop.
-				// But client.Run should be restartable if we just loop?
-				// Actually, `client` struct might hold some state.
-				// Better to create a new client instance?
-				// The current structure creates `client` variable outside.
-				// Let's just recreate the client inside the loop if we want to be safe,
-				// but StartAndListen takes the struct receiver `c`.
-				// The `c.client` is assigned.
-
-				// Ideally we should rebuild the client.
-				// Let's modify the code to rebuild client inside the loop if needed.
-				// But we are inside StartAndListen.
-
-				// Simplest valid "Retry" is to:
-				// 1. Delete session.
-				// 2. Return error? No, we want to stay alive.
-
-				// If we just continue the loop, we call `client.Run` again on the *same* client instance.
-				// GOTD client might be in a closed state.
-				// Let's try to re-initialize the client variable.
-
-				newClient := telegram.NewClient(appIDInt, appHash, telegram.Options{
-					SessionStorage: &telegram.FileSessionStorage{
-						Path: "session/session.json",
-					},
-				})
-				c.client = newClient
-				client = newClient
+		// The connection is gone, API calls must fail until the next one is up
+		c.setConnection(nil, nil, c.CurrentUser())
+		reconnect := runCtx.Err() != nil && ctx.Err() == nil
+		disconnect()
 
-				continue
+		if reconnect {
+			return errReconnect
+		}
+
+		if err != nil && strings.Contains(err.Error(), "AUTH_RESTART") {
+			c.log.Warn("Received AUTH_RESTART, deleting session and restarting")
+			// Delete this account's session file to force re-auth
+			if rErr := os.Remove(c.sessionPath); rErr != nil && !os.IsNotExist(rErr) {
+				c.log.Error("Failed to remove session file", "path", c.sessionPath, "err", rErr)
 			}
-			return err
+			continue
 		}
 
-		// If no error, it means ctx was canceled or normal exit
-		return nil
+		// No error means ctx was canceled or onReady returned
+		return err
 	}
 }
 
-type termAuth struct{}
+// termAuthMu serializes interactive logins of several accounts.
+var termAuthMu sync.Mutex
+
+type termAuth struct {
+	account string
+}
 
-func (termAuth) Phone(_ context.Context) (string, error) {
-	fmt.Print("Enter phone: ")
+func (a termAuth) Phone(_ context.Context) (string, error) {
+	fmt.Printf("[%s] Enter phone: ", a.account)
 	phone, err := bufio.NewReader(os.Stdin).ReadString('\n')
 	if err != nil {
 		return "", err
@@ -160,8 +256,8 @@ func (termAuth) Phone(_ context.Context) (string, error) {
 	return strings.TrimSpace(phone), nil
 }
 
-func (termAuth) Code(ctx context.Context, sentCode *tg.AuthSentCode) (string, error) {
-	fmt.Print("Enter code: ")
+func (a termAuth) Code(ctx context.Context, sentCode *tg.AuthSentCode) (string, error) {
+	fmt.Printf("[%s] Enter code: ", a.account)
 	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
 	if err != nil {
 		return "", err
@@ -177,8 +273,8 @@ func (termAuth) AcceptTermsOfService(ctx context.Context, tos tg.HelpTermsOfServ
 	return nil
 }
 
-func (termAuth) Password(ctx context.Context) (string, error) {
-	fmt.Print("Enter 2FA password: ")
+func (a termAuth) Password(ctx context.Context) (string, error) {
+	fmt.Printf("[%s] Enter 2FA password: ", a.account)
 	pass, err := bufio.NewReader(os.Stdin).ReadString('\n')
 	if err != nil {
 		return "", err
@@ -208,52 +304,56 @@ type SavedMessage struct {
 	MediaType   string          `json:"media_type,omitempty"` // For backward compatibility / single media
 	Attachments []MediaItem     `json:"attachments,omitempty"`
 	GroupedID   int64           `json:"grouped_id,omitempty"`
+	Pinned      bool            `json:"pinned,omitempty"`
 	WebPreview  *WebPagePreview `json:"web_preview,omitempty"`
+
+	// Structured content of other media, see richMediaType. A venue sets
+	// both Location and Venue.
+	Poll     *Poll     `json:"poll,omitempty"`
+	Contact  *Contact  `json:"contact,omitempty"`
+	Location *Location `json:"location,omitempty"`
+	Venue    *Venue    `json:"venue,omitempty"`
+	Dice     *Dice     `json:"dice,omitempty"`
 }
 
 // GetSavedMessages fetches the history of 'Saved Messages' (InputPeerSelf).
+// See GetMessagePage for cursor-based paging that keeps albums together.
 func (c *Client) GetSavedMessages(ctx context.Context, offsetID int, limit int, addOffset int) ([]SavedMessage, int, error) {
-	if c.api == nil {
-		return nil, 0, errors.New("client not initialized")
+	if _, err := c.rpc(); err != nil {
+		return nil, 0, err
 	}
 
 	if limit <= 0 {
-		limit = 20
+		limit = c.opts.DefaultPageSize
 	}
-	if limit > 100 {
-		limit = 100
+	if limit > c.opts.MaxPageSize {
+		limit = c.opts.MaxPageSize
 	}
 
-	history, err := c.api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+	messages, totalCount, err := c.getHistory(ctx, &tg.MessagesGetHistoryRequest{
 		Peer:      &tg.InputPeerSelf{},
 		OffsetID:  offsetID,
 		Limit:     limit,
 		AddOffset: addOffset,
 	})
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get history: %w", err)
+		return nil, 0, err
 	}
 
-	var messages []tg.MessageClass
-	var totalCount int
-
-	switch h := history.(type) {
-	case *tg.MessagesMessages:
-		messages = h.Messages
-		totalCount = len(messages)
-		fmt.Printf("[DEBUG] Got MessagesMessages. Count: %d\n", totalCount)
-	case *tg.MessagesMessagesSlice:
-		messages = h.Messages
-		totalCount = h.Count
-		fmt.Printf("[DEBUG] Got MessagesMessagesSlice. Count: %d, Len: %d\n", totalCount, len(messages))
-	case *tg.MessagesChannelMessages:
-		messages = h.Messages
-		totalCount = h.Count
-		fmt.Printf("[DEBUG] Got MessagesChannelMessages. Count: %d\n", totalCount)
-	default:
-		return nil, 0, fmt.Errorf("unexpected history type: %T", history)
+	// An album cut by either end of the slice would show up as a partial
+	// card (and deleting it would leave orphans), so fetch its other members.
+	// The history is newest first, i.e. walking towards older messages.
+	messages, err = c.completeAlbums(ctx, messages, true, len(messages) >= limit)
+	if err != nil {
+		return nil, 0, err
 	}
 
+	return groupMessages(messages), totalCount, nil
+}
+
+// groupMessages converts raw Telegram messages into SavedMessages,
+// merging adjacent members of the same album into a single item.
+func groupMessages(messages []tg.MessageClass) []SavedMessage {
 	var result []SavedMessage
 
 	// Messages usually come new to old.
@@ -285,7 +385,7 @@ func (c *Client) GetSavedMessages(ctx context.Context, offsetID int, limit int,
 					}
 				}
 			default:
-				mediaType = "Media"
+				mediaType = richMediaType(media)
 			}
 		}
 
@@ -304,6 +404,11 @@ func (c *Client) GetSavedMessages(ctx context.Context, offsetID int, limit int,
 				merged = true
 				last.IDs = append(last.IDs, m.ID)
 
+				// An album counts as pinned if any of its members is pinned
+				if m.Pinned {
+					last.Pinned = true
+				}
+
 				// Keep text if current has it and last didn't (or append? usually caption is on one)
 				if last.Message == "" && m.Message != "" {
 					last.Message = m.Message
@@ -328,9 +433,11 @@ func (c *Client) GetSavedMessages(ctx context.Context, offsetID int, limit int,
 				Message:     m.Message,
 				MediaType:   mediaType, // Keep for single display or fallback
 				GroupedID:   m.GroupedID,
+				Pinned:      m.Pinned,
 				Attachments: []MediaItem{},
 				WebPreview:  webPreview,
 			}
+			item.setRichMedia(m.Media)
 
 			// If it has media, add to attachments too for consistency
 			if mediaType == "Photo" || mediaType == "Document" {
@@ -344,68 +451,172 @@ func (c *Client) GetSavedMessages(ctx context.Context, offsetID int, limit int,
 		}
 	}
 
-	return result, totalCount, nil
+	return result
 }
 
 // DeleteMessages deletes messages by ID from Saved Messages.
 func (c *Client) DeleteMessages(ctx context.Context, ids []int) error {
-	if c.api == nil {
-		return errors.New("client not initialized")
+	api, err := c.rpc()
+	if err != nil {
+		return err
 	}
 
 	if len(ids) == 0 {
 		return nil
 	}
 
-	_, err := c.api.MessagesDeleteMessages(ctx, &tg.MessagesDeleteMessagesRequest{
+	_, err = api.MessagesDeleteMessages(ctx, &tg.MessagesDeleteMessagesRequest{
 		Revoke: true,
 		ID:     ids,
 	})
+	if err == nil {
+		c.mediaCache.remove(ids...)
+		messagesDeleted.Add(float64(len(ids)), c.Account)
+	}
 
 	return err
 }
 
-// GetMessageMedia downloads the media for a given message ID.
-func (c *Client) GetMessageMedia(ctx context.Context, msgID int) ([]byte, string, error) {
-	if c.api == nil {
-		return nil, "", errors.New("client not initialized")
+// GetMessageMedia downloads the media for a given message ID. Downloads are
+// queued by priority and run on a few workers; concurrent calls for the
+// same message share one download.
+func (c *Client) GetMessageMedia(ctx context.Context, msgID int, priority MediaPriority) ([]byte, string, error) {
+	if _, err := c.rpc(); err != nil {
+		return nil, "", err
 	}
 
-	// 1. Get the message
-	msgs, err := c.api.MessagesGetMessages(ctx, []tg.InputMessageClass{
-		&tg.InputMessageID{ID: msgID},
-	})
+	if data, contentType, ok := c.mediaCache.get(msgID); ok {
+		mediaCacheRequests.Inc(c.Account, "hit")
+		return data, contentType, nil
+	}
+	mediaCacheRequests.Inc(c.Account, "miss")
+
+	return c.media.fetch(ctx, msgID, priority)
+}
+
+// downloadMedia downloads the media described by src and caches it.
+func (c *Client) downloadMedia(ctx context.Context, api *tg.Client, msgID int, src mediaSource) ([]byte, error) {
+	data := bytes.NewBuffer(nil)
+	if err := c.streamMedia(ctx, api, msgID, src, data); err != nil {
+		return nil, err
+	}
+	c.mediaCache.put(msgID, data.Bytes(), src.contentType)
+	return data.Bytes(), nil
+}
+
+// streamMedia downloads the media described by src into w, chunk by chunk,
+// without caching it.
+func (c *Client) streamMedia(ctx context.Context, api *tg.Client, msgID int, src mediaSource, w io.Writer) error {
+	// File references expire, possibly halfway through a large file. The
+	// client refreshes them and retries the chunk it was at, so the download
+	// resumes instead of failing.
+	rpc := &refreshingClient{
+		Client:   api,
+		location: src.location,
+		refresh: func(ctx context.Context) (tg.InputFileLocationClass, error) {
+			location, _, err := c.mediaLocation(ctx, api, msgID)
+			return location, err
+		},
+		log: c.log.With("id", msgID),
+	}
+
+	d := downloader.NewDownloader()
+	cw := &countingWriter{w: w}
+
+	_, err := d.Download(rpc, src.location).Stream(ctx, cw)
+	mediaBytes.Add(float64(cw.n), c.Account)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	if cw.n == 0 {
+		return fmt.Errorf("downloaded 0 bytes for message %d", msgID)
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written through it and keeps the first
+// write error, to tell a failed write from a failed download.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	if err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return n, err
+}
+
+// mediaLocation fetches a message and returns the location and content type
+// of its media. Every call gets a fresh file reference.
+func (c *Client) mediaLocation(ctx context.Context, api *tg.Client, msgID int) (tg.InputFileLocationClass, string, error) {
+	sources, err := c.mediaSources(ctx, api, []int{msgID})
+	if err != nil {
+		return nil, "", err
+	}
+	src := sources[msgID]
+	return src.location, src.contentType, src.err
+}
+
+// mediaSource is where to download the media of one message from, or why
+// it cannot be downloaded.
+type mediaSource struct {
+	location    tg.InputFileLocationClass
+	contentType string
+	err         error
+}
+
+// maxLookupBatch is the most messages fetched by one messages.getMessages.
+const maxLookupBatch = 100
+
+// mediaSources fetches up to maxLookupBatch messages in a single request
+// and returns the media source of each. Messages without media get an
+// error source rather than failing the whole batch.
+func (c *Client) mediaSources(ctx context.Context, api *tg.Client, ids []int) (map[int]mediaSource, error) {
+	input := make([]tg.InputMessageClass, len(ids))
+	for i, id := range ids {
+		input[i] = &tg.InputMessageID{ID: id}
+	}
+
+	msgs, err := api.MessagesGetMessages(ctx, input)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get message: %w", err)
+		return nil, fmt.Errorf("failed to get message: %w", err)
 	}
 
-	var msg *tg.Message
+	var list []tg.MessageClass
 	switch m := msgs.(type) {
 	case *tg.MessagesMessages:
-		if len(m.Messages) > 0 {
-			if mm, ok := m.Messages[0].(*tg.Message); ok {
-				msg = mm
-			}
-		}
+		list = m.Messages
 	case *tg.MessagesMessagesSlice:
-		if len(m.Messages) > 0 {
-			if mm, ok := m.Messages[0].(*tg.Message); ok {
-				msg = mm
-			}
-		}
+		list = m.Messages
 	case *tg.MessagesChannelMessages:
-		if len(m.Messages) > 0 {
-			if mm, ok := m.Messages[0].(*tg.Message); ok {
-				msg = mm
-			}
-		}
+		list = m.Messages
 	}
 
-	if msg == nil || msg.Media == nil {
-		return nil, "", errors.New("message media not found")
+	sources := make(map[int]mediaSource, len(ids))
+	for _, id := range ids {
+		sources[id] = mediaSource{err: errors.New("message media not found")}
+	}
+	for _, m := range list {
+		msg, ok := m.(*tg.Message)
+		if !ok || msg.Media == nil {
+			continue
+		}
+		location, contentType, err := c.locationOf(msg)
+		sources[msg.ID] = mediaSource{location: location, contentType: contentType, err: err}
 	}
 
-	// 2. Determine location and content type
+	return sources, nil
+}
+
+// locationOf returns the location and content type of a message's media.
+func (c *Client) locationOf(msg *tg.Message) (tg.InputFileLocationClass, string, error) {
+	// Determine location and content type
 	var location tg.InputFileLocationClass
 	contentType := "application/octet-stream"
 
@@ -425,7 +636,7 @@ func (c *Client) GetMessageMedia(ctx context.Context, msgID int) ([]byte, string
 		for _, s := range photo.Sizes {
 			if sz, ok := s.(*tg.PhotoSize); ok {
 				// Log what we see
-				fmt.Printf("[DEBUG] Photo %d size: %s (%dx%d)\n", photo.ID, sz.Type, sz.W, sz.H)
+				c.log.Debug("Photo size", "photo_id", photo.ID, "type", sz.Type, "w", sz.W, "h", sz.H)
 				if sz.Type == "w" || sz.Type == "y" {
 					bestSize = sz.Type
 					break
@@ -435,7 +646,7 @@ func (c *Client) GetMessageMedia(ctx context.Context, msgID int) ([]byte, string
 				}
 			}
 			if sz, ok := s.(*tg.PhotoSizeProgressive); ok {
-				fmt.Printf("[DEBUG] Photo %d progressive size: %s (%dx%d)\n", photo.ID, sz.Type, sz.W, sz.H)
+				c.log.Debug("Photo progressive size", "photo_id", photo.ID, "type", sz.Type, "w", sz.W, "h", sz.H)
 				if sz.Type == "w" || sz.Type == "y" {
 					bestSize = sz.Type
 					break
@@ -461,7 +672,7 @@ func (c *Client) GetMessageMedia(ctx context.Context, msgID int) ([]byte, string
 			return nil, "", fmt.Errorf("no suitable photo size found for photo %d", photo.ID)
 		}
 
-		fmt.Printf("[DEBUG] Selected size '%s' for photo %d\n", bestSize, photo.ID)
+		c.log.Debug("Selected photo size", "photo_id", photo.ID, "type", bestSize)
 
 		location = &tg.InputPhotoFileLocation{
 			ID:            photo.ID,
@@ -546,18 +757,5 @@ func (c *Client) GetMessageMedia(ctx context.Context, msgID int) ([]byte, string
 		return nil, "", fmt.Errorf("unsupported media type: %T", msg.Media)
 	}
 
-	// 3. Download
-	d := downloader.NewDownloader()
-	data := bytes.NewBuffer(nil)
-
-	_, err = d.Download(c.api, location).Stream(ctx, data)
-	if err != nil {
-		return nil, "", fmt.Errorf("download failed: %w", err)
-	}
-
-	if data.Len() == 0 {
-		return nil, "", fmt.Errorf("downloaded 0 bytes for message %d", msgID)
-	}
-
-	return data.Bytes(), contentType, nil
+	return location, contentType, nil
 }
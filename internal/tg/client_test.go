@@ -0,0 +1,327 @@
+package tg
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/gotd/td/tg"
+
+	"telegram-manager/internal/proxy"
+	"telegram-manager/internal/proxy/proxytest"
+)
+
+func TestGetSavedMessagesGroupsAlbums(t *testing.T) {
+	s := newTestServer(t)
+	// The album caption is usually on one of the members only
+	captioned := photoMessage(5, 2, photoSizes("x")...)
+	captioned.Message = "caption"
+
+	s.addMessages(textMessage(7, "newest"))
+	s.addMessages(inAlbum(100,
+		photoMessage(6, 1, photoSizes("x")...),
+		captioned,
+		photoMessage(4, 3, photoSizes("x")...),
+	)...)
+	s.addMessages(documentMessage(3, 4, "application/pdf", 10))
+	s.addMessages(textMessage(2, "oldest"))
+
+	c := startClient(t, s, Options{})
+	ctx := testContext(t)
+
+	// The limit cuts the album after its first photo; the rest of it must
+	// still come back as one item
+	msgs, total, err := c.GetSavedMessages(ctx, 0, 2, 0)
+	if err != nil {
+		t.Fatalf("GetSavedMessages failed: %v", err)
+	}
+	if total != 6 {
+		t.Errorf("total = %d, want 6", total)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("got %d items, want 2: %+v", len(msgs), msgs)
+	}
+
+	if msgs[0].ID != 7 || msgs[0].Message != "newest" || msgs[0].MediaType != "" {
+		t.Errorf("first item = %+v, want the text message 7", msgs[0])
+	}
+
+	album := msgs[1]
+	if !slices.Equal(album.IDs, []int{6, 5, 4}) {
+		t.Errorf("album IDs = %v, want [6 5 4]", album.IDs)
+	}
+	if album.GroupedID != 100 || album.MediaType != "Photo" || album.Message != "caption" {
+		t.Errorf("album = %+v, want a captioned photo album", album)
+	}
+	if len(album.Attachments) != 3 {
+		t.Errorf("album has %d attachments, want 3", len(album.Attachments))
+	}
+
+	// The next page starts after the album
+	msgs, _, err = c.GetSavedMessages(ctx, 4, 10, 0)
+	if err != nil {
+		t.Fatalf("GetSavedMessages failed: %v", err)
+	}
+	var ids []int
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+	if !slices.Equal(ids, []int{3, 2}) {
+		t.Errorf("next page IDs = %v, want [3 2]", ids)
+	}
+	if msgs[0].MediaType != "Document" || len(msgs[0].Attachments) != 1 {
+		t.Errorf("document item = %+v, want one document attachment", msgs[0])
+	}
+}
+
+func TestGetMessagePageKeepsAlbumsTogether(t *testing.T) {
+	s := newTestServer(t)
+	s.addMessages(textMessage(9, "a"), textMessage(8, "b"))
+	s.addMessages(inAlbum(200,
+		photoMessage(7, 1, photoSizes("x")...),
+		photoMessage(6, 2, photoSizes("x")...),
+		photoMessage(5, 3, photoSizes("x")...),
+	)...)
+	s.addMessages(textMessage(4, "c"), textMessage(3, "d"), textMessage(2, "e"))
+
+	c := startClient(t, s, Options{})
+	ctx := testContext(t)
+
+	for _, order := range []Order{OrderDesc, OrderAsc} {
+		t.Run(string(order), func(t *testing.T) {
+			var ids []int
+			cursor := Cursor{Order: order}
+			for pages := 0; ; pages++ {
+				if pages > 10 {
+					t.Fatalf("paging did not end, got %v so far", ids)
+				}
+
+				page, err := c.GetMessagePage(ctx, cursor, 2)
+				if err != nil {
+					t.Fatalf("GetMessagePage failed: %v", err)
+				}
+				for _, m := range page.Messages {
+					if m.GroupedID != 0 && len(m.IDs) != 3 {
+						t.Errorf("album split between pages: %v", m.IDs)
+					}
+					ids = append(ids, m.IDs...)
+				}
+
+				if page.NextCursor == "" {
+					break
+				}
+				if cursor, err = ParseCursor(page.NextCursor); err != nil {
+					t.Fatalf("invalid next cursor: %v", err)
+				}
+			}
+
+			want := []int{9, 8, 7, 6, 5, 4, 3, 2}
+			if order == OrderAsc {
+				slices.Reverse(want)
+			}
+			if !slices.Equal(ids, want) {
+				t.Errorf("IDs = %v, want %v", ids, want)
+			}
+		})
+	}
+}
+
+func TestPhotoSizeSelection(t *testing.T) {
+	tests := []struct {
+		name  string
+		sizes []tg.PhotoSizeClass
+		want  string
+	}{
+		{"prefers y", photoSizes("s", "m", "x", "y"), "y"},
+		{"prefers w", photoSizes("s", "m", "x", "w"), "w"},
+		{"falls back to x", photoSizes("s", "m", "x"), "x"},
+		{"falls back to the last size", photoSizes("s", "m"), "m"},
+		{
+			"progressive",
+			[]tg.PhotoSizeClass{
+				&tg.PhotoStrippedSize{Type: "i", Bytes: []byte{1}},
+				&tg.PhotoSizeProgressive{Type: "y", W: 1280, H: 960, Sizes: []int{100, 200}},
+			},
+			"y",
+		},
+	}
+
+	s := newTestServer(t)
+	for i, tt := range tests {
+		id := 100 - i
+		s.addMessages(photoMessage(id, int64(id), tt.sizes...))
+		s.addFile(int64(id), []byte(tt.name))
+	}
+
+	c := startClient(t, s, Options{})
+	ctx := testContext(t)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, contentType, err := c.GetMessageMedia(ctx, 100-i, PriorityNormal)
+			if err != nil {
+				t.Fatalf("GetMessageMedia failed: %v", err)
+			}
+			if string(data) != tt.name || contentType != "image/jpeg" {
+				t.Errorf("got %q (%s), want %q (image/jpeg)", data, contentType, tt.name)
+			}
+
+			s.mu.Lock()
+			thumb := s.thumbs[len(s.thumbs)-1]
+			s.mu.Unlock()
+			if thumb != tt.want {
+				t.Errorf("requested size %q, want %q", thumb, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetMessageMediaDownloadsDocument(t *testing.T) {
+	// A few chunks of 512 KiB, the last one partial
+	data := make([]byte, 3*512*1024+1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	s := newTestServer(t)
+	s.addMessages(documentMessage(1, 42, "video/mp4", int64(len(data))))
+	s.addFile(42, data)
+
+	c := startClient(t, s, Options{MediaCacheBytes: 10 << 20})
+	ctx := testContext(t)
+
+	got, contentType, err := c.GetMessageMedia(ctx, 1, PriorityHigh)
+	if err != nil {
+		t.Fatalf("GetMessageMedia failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("downloaded %d bytes that differ from the %d served", len(got), len(data))
+	}
+	if contentType != "video/mp4" {
+		t.Errorf("content type = %q, want video/mp4", contentType)
+	}
+
+	// The second request is served from the cache
+	s.mu.Lock()
+	calls := s.getFileCalls
+	s.mu.Unlock()
+	if _, _, err := c.GetMessageMedia(ctx, 1, PriorityNormal); err != nil {
+		t.Fatalf("GetMessageMedia from cache failed: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.getFileCalls != calls {
+		t.Errorf("cached media was downloaded again (%d getFile calls, want %d)", s.getFileCalls, calls)
+	}
+}
+
+func TestGetMessageMediaRefreshesExpiredReference(t *testing.T) {
+	data := make([]byte, 3*512*1024)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+
+	s := newTestServer(t)
+	s.addMessages(documentMessage(1, 42, "application/zip", int64(len(data))))
+	s.addFile(42, data)
+	// Expires halfway through the download
+	s.expireReference(42, 512*1024)
+
+	c := startClient(t, s, Options{})
+	ctx := testContext(t)
+
+	got, _, err := c.GetMessageMedia(ctx, 1, PriorityNormal)
+	if err != nil {
+		t.Fatalf("GetMessageMedia failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("downloaded %d bytes that differ from the %d served", len(got), len(data))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// One lookup to start the download, one to refresh the reference
+	if s.getMessagesCalls != 2 {
+		t.Errorf("messages.getMessages called %d times, want 2", s.getMessagesCalls)
+	}
+	// Three chunks and the empty one ending the download, plus the rejected
+	// one; a restarted download would fetch the first chunk again
+	if s.getFileCalls != 5 {
+		t.Errorf("upload.getFile called %d times, want 5", s.getFileCalls)
+	}
+}
+
+func TestGetMessageMediaWithoutMedia(t *testing.T) {
+	s := newTestServer(t)
+	s.addMessages(textMessage(1, "no media"))
+
+	c := startClient(t, s, Options{})
+	ctx := testContext(t)
+
+	if _, _, err := c.GetMessageMedia(ctx, 1, PriorityNormal); err == nil {
+		t.Error("GetMessageMedia succeeded for a text message")
+	}
+	if _, _, err := c.GetMessageMedia(ctx, 99, PriorityNormal); err == nil {
+		t.Error("GetMessageMedia succeeded for a missing message")
+	}
+}
+
+func TestDeleteMessages(t *testing.T) {
+	s := newTestServer(t)
+	s.addMessages(textMessage(4, "d"), textMessage(3, "c"), textMessage(2, "b"), textMessage(1, "a"))
+
+	c := startClient(t, s, Options{})
+	ctx := testContext(t)
+
+	if err := c.DeleteMessages(ctx, []int{3, 1}); err != nil {
+		t.Fatalf("DeleteMessages failed: %v", err)
+	}
+	if ids := s.ids(); !slices.Equal(ids, []int{4, 2}) {
+		t.Errorf("history after delete = %v, want [4 2]", ids)
+	}
+
+	msgs, total, err := c.GetSavedMessages(ctx, 0, 10, 0)
+	if err != nil {
+		t.Fatalf("GetSavedMessages failed: %v", err)
+	}
+	if total != 2 || len(msgs) != 2 {
+		t.Errorf("got %d of %d messages after delete, want 2 of 2", len(msgs), total)
+	}
+}
+
+func TestConnectThroughProxy(t *testing.T) {
+	tests := []struct {
+		name   string
+		server *proxytest.Server
+		url    func(addr string) string
+	}{
+		{"socks5", proxytest.NewSOCKS5("alice", "pw"), func(addr string) string { return "socks5://alice:pw@" + addr }},
+		{"http", proxytest.NewHTTP("", ""), func(addr string) string { return "http://" + addr }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(tt.server.Close)
+
+			p, err := proxy.Parse(tt.url(tt.server.Addr))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := newTestServer(t)
+			s.addMessages(textMessage(1, "via proxy"))
+
+			c := startClient(t, s, Options{Proxy: p})
+			msgs, _, err := c.GetSavedMessages(testContext(t), 0, 10, 0)
+			if err != nil {
+				t.Fatalf("GetSavedMessages failed: %v", err)
+			}
+			if len(msgs) != 1 || msgs[0].Message != "via proxy" {
+				t.Errorf("got %+v, want the message", msgs)
+			}
+			if tt.server.Tunnels() == 0 {
+				t.Error("the client did not connect through the proxy")
+			}
+		})
+	}
+}
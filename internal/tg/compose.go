@@ -0,0 +1,143 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/telegram/message/styling"
+	"github.com/gotd/td/telegram/uploader"
+	"github.com/gotd/td/tg"
+)
+
+// ParseMode controls how outgoing text is converted into Telegram entities.
+type ParseMode string
+
+const (
+	// ParseModePlain sends the text as-is, without any formatting entities.
+	ParseModePlain ParseMode = ""
+	// ParseModeHTML parses the Telegram HTML subset (<b>, <i>, <u>, <s>,
+	// <code>, <pre>, <a href>, <tg-spoiler>, <blockquote>) into entities.
+	ParseModeHTML ParseMode = "html"
+)
+
+// styledText converts text into a gotd styled text option according to mode.
+func styledText(text string, mode ParseMode) (message.StyledTextOption, error) {
+	switch mode {
+	case ParseModePlain:
+		return styling.Plain(text), nil
+	case ParseModeHTML:
+		return html.String(nil, text), nil
+	default:
+		return message.StyledTextOption{}, fmt.Errorf("unknown parse mode: %q", mode)
+	}
+}
+
+// SendText sends a new text note to Saved Messages and returns its ID.
+func (c *Client) SendText(ctx context.Context, text string, mode ParseMode) (int, error) {
+	api, err := c.rpc()
+	if err != nil {
+		return 0, err
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return 0, errors.New("message text is empty")
+	}
+
+	styled, err := styledText(text, mode)
+	if err != nil {
+		return 0, err
+	}
+
+	upd, err := message.NewSender(api).Self().StyledText(ctx, styled)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return sentMessageID(upd), nil
+}
+
+// SendFile uploads a file and sends it to Saved Messages with an optional caption.
+// JPEG, PNG and WebP images are sent as photos, everything else as documents.
+func (c *Client) SendFile(ctx context.Context, name string, mimeType string, r io.Reader, size int64, caption string, mode ParseMode) (int, error) {
+	api, err := c.rpc()
+	if err != nil {
+		return 0, err
+	}
+
+	var captionOpts []message.StyledTextOption
+	if caption != "" {
+		styled, err := styledText(caption, mode)
+		if err != nil {
+			return 0, err
+		}
+		captionOpts = append(captionOpts, styled)
+	}
+
+	file, err := uploader.NewUploader(api).Upload(ctx, uploader.NewUpload(name, r, size))
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	var media message.MediaOption
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/webp":
+		media = message.UploadedPhoto(file, captionOpts...)
+	default:
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		media = message.UploadedDocument(file, captionOpts...).
+			MIME(mimeType).
+			Filename(name)
+	}
+
+	upd, err := message.NewSender(api).Self().Media(ctx, media)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send file: %w", err)
+	}
+
+	return sentMessageID(upd), nil
+}
+
+// EditMessage replaces the text (or caption) of an existing message in Saved Messages.
+func (c *Client) EditMessage(ctx context.Context, id int, text string, mode ParseMode) error {
+	api, err := c.rpc()
+	if err != nil {
+		return err
+	}
+
+	styled, err := styledText(text, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := message.NewSender(api).Self().Edit(id).StyledText(ctx, styled); err != nil {
+		return fmt.Errorf("failed to edit message %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// sentMessageID extracts the ID of a freshly sent message from the updates
+// returned by messages.sendMessage / messages.sendMedia. Returns 0 if not found.
+func sentMessageID(upd tg.UpdatesClass) int {
+	switch u := upd.(type) {
+	case *tg.UpdateShortSentMessage:
+		return u.ID
+	case *tg.Updates:
+		for _, update := range u.Updates {
+			switch v := update.(type) {
+			case *tg.UpdateNewMessage:
+				return v.Message.GetID()
+			case *tg.UpdateMessageID:
+				return v.ID
+			}
+		}
+	}
+	return 0
+}
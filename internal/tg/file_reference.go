@@ -0,0 +1,70 @@
+package tg
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/gotd/td/tg"
+)
+
+// maxReferenceRefreshes bounds how often one chunk is retried with a fresh
+// file reference, in case Telegram keeps rejecting them.
+const maxReferenceRefreshes = 3
+
+// refreshingClient is the RPC client given to the downloader. When a chunk
+// fails with FILE_REFERENCE_EXPIRED it fetches a fresh location through
+// refresh and requests the same chunk again. The downloader only sees the
+// chunk arrive late, so the download carries on from the offset it had
+// reached instead of starting over or failing.
+type refreshingClient struct {
+	*tg.Client
+	refresh func(ctx context.Context) (tg.InputFileLocationClass, error)
+	log     *slog.Logger
+
+	mu       sync.Mutex
+	location tg.InputFileLocationClass
+}
+
+// UploadGetFile implements downloader.Client.
+func (r *refreshingClient) UploadGetFile(ctx context.Context, req *tg.UploadGetFileRequest) (tg.UploadFileClass, error) {
+	for attempt := 0; ; attempt++ {
+		// The downloader builds every request from the original location
+		req.Location = r.current()
+
+		file, err := r.Client.UploadGetFile(ctx, req)
+		if !tg.IsFileReferenceExpired(err) || attempt == maxReferenceRefreshes {
+			return file, err
+		}
+
+		r.log.Info("File reference expired, refreshing", "offset", req.Offset)
+		if err := r.renew(ctx, req.Location); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *refreshingClient) current() tg.InputFileLocationClass {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.location
+}
+
+// renew replaces the expired location. Parallel chunks can hit the expiry
+// together; only the first one refetches, the others use its result.
+func (r *refreshingClient) renew(ctx context.Context, expired tg.InputFileLocationClass) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.location != expired {
+		return nil
+	}
+
+	location, err := r.refresh(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to refresh file reference: %w", err)
+	}
+	r.location = location
+	return nil
+}
@@ -0,0 +1,485 @@
+package tg
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"github.com/gotd/td/tgtest"
+	"github.com/gotd/td/tgtest/cluster"
+)
+
+// selfID is the user the test client is logged in as.
+const selfID = 10
+
+// testServer is an in-process Telegram DC serving a scripted Saved Messages
+// chat. It answers just enough of the API for the client to log in and to
+// list, delete and download messages.
+type testServer struct {
+	cluster *cluster.Cluster
+
+	mu sync.Mutex
+	// messages is the chat history, newest first.
+	messages []*tg.Message
+	// files holds the content of photos and documents by their ID.
+	files map[int64][]byte
+	// reference is the current file reference of every file. Locations
+	// with another reference are rejected with FILE_REFERENCE_EXPIRED.
+	reference byte
+	// expireAt makes the reference expire once, when a chunk at or past
+	// the offset is requested for the file with that ID.
+	expireAt map[int64]int64
+
+	// thumbs are the photo sizes requested by upload.getFile, in order.
+	thumbs []string
+	// getFileCalls counts upload.getFile requests, including rejected ones.
+	getFileCalls int
+	// getMessagesCalls counts messages.getMessages requests.
+	getMessagesCalls int
+
+	// takeoutDelays is how many account.initTakeoutSession requests are
+	// answered with TAKEOUT_INIT_DELAY before the session is granted, as
+	// if the user allowed it meanwhile. Negative refuses it for good.
+	takeoutDelays int
+	// takeoutRequests counts requests made in a takeout session, by type.
+	takeoutRequests map[uint32]int
+	// takeoutFinished records the success flag of every finished takeout
+	// session.
+	takeoutFinished []bool
+}
+
+// testTakeoutID is the ID of the takeout session granted by testServer.
+const testTakeoutID = 77
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	s := &testServer{
+		cluster:  cluster.NewCluster(cluster.Options{}),
+		files:    map[int64][]byte{},
+		expireAt: map[int64]int64{},
+
+		takeoutRequests: map[uint32]int{},
+	}
+
+	// auth.Status and Self both ask for the current user
+	s.cluster.Common().Vector(tg.UsersGetUsersRequestTypeID, &tg.User{
+		Self:       true,
+		ID:         selfID,
+		AccessHash: selfID,
+		FirstName:  "Test",
+		Username:   "test",
+	})
+
+	d := s.cluster.Dispatch(2, "server")
+	d.HandleFunc(tg.MessagesGetHistoryRequestTypeID, s.getHistory).
+		HandleFunc(tg.MessagesGetMessagesRequestTypeID, s.getMessages).
+		HandleFunc(tg.MessagesDeleteMessagesRequestTypeID, s.deleteMessages).
+		HandleFunc(tg.UploadGetFileRequestTypeID, s.getFile).
+		HandleFunc(tg.AccountInitTakeoutSessionRequestTypeID, s.initTakeout).
+		HandleFunc(tg.AccountFinishTakeoutSessionRequestTypeID, s.finishTakeout).
+		HandleFunc(tg.InvokeWithTakeoutRequestTypeID, func(server *tgtest.Server, req *tgtest.Request) error {
+			return s.invokeWithTakeout(d, server, req)
+		})
+
+	return s
+}
+
+// addMessages appends messages to the history. They must be given newest
+// first and be older than the messages already there.
+func (s *testServer) addMessages(msgs ...*tg.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range msgs {
+		if m.PeerID == nil {
+			m.PeerID = &tg.PeerUser{UserID: selfID}
+		}
+		if m.Date == 0 {
+			m.Date = 1700000000 + m.ID
+		}
+		s.messages = append(s.messages, m)
+	}
+}
+
+// addFile sets the content served for the photo or document with the ID.
+func (s *testServer) addFile(id int64, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[id] = data
+}
+
+// expireReference makes the file reference expire once a chunk at offset
+// or beyond is requested for the file with the ID.
+func (s *testServer) expireReference(id int64, offset int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireAt[id] = offset
+}
+
+// ids returns the IDs of the messages left in the history, newest first.
+func (s *testServer) ids() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int, len(s.messages))
+	for i, m := range s.messages {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+func (s *testServer) getHistory(server *tgtest.Server, req *tgtest.Request) error {
+	var r tg.MessagesGetHistoryRequest
+	if err := r.Decode(req.Buf); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Like Telegram: the slice starts at the first message older than
+	// offset_id (or offset_date), shifted by add_offset, so a negative
+	// add_offset reaches into newer messages.
+	pos := 0
+	switch {
+	case r.OffsetID != 0:
+		pos = len(s.messages)
+		for i, m := range s.messages {
+			if m.ID < r.OffsetID {
+				pos = i
+				break
+			}
+		}
+	case r.OffsetDate != 0:
+		pos = len(s.messages)
+		for i, m := range s.messages {
+			if m.Date < r.OffsetDate {
+				pos = i
+				break
+			}
+		}
+	}
+
+	// Clamped after computing both ends: a window reaching past the newest
+	// message is cut short rather than shifted
+	start := pos + r.AddOffset
+	end := min(start+r.Limit, len(s.messages))
+	start = max(start, 0)
+
+	var page []tg.MessageClass
+	for i := start; i < end; i++ {
+		page = append(page, s.withReference(s.messages[i]))
+	}
+
+	return server.SendResult(req, &tg.MessagesMessagesSlice{
+		Count:    len(s.messages),
+		Messages: page,
+	})
+}
+
+func (s *testServer) getMessages(server *tgtest.Server, req *tgtest.Request) error {
+	var r tg.MessagesGetMessagesRequest
+	if err := r.Decode(req.Buf); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.getMessagesCalls++
+
+	var found []tg.MessageClass
+	for _, input := range r.ID {
+		id, ok := input.(*tg.InputMessageID)
+		if !ok {
+			continue
+		}
+		for _, m := range s.messages {
+			if m.ID == id.ID {
+				found = append(found, s.withReference(m))
+			}
+		}
+	}
+
+	return server.SendResult(req, &tg.MessagesMessages{Messages: found})
+}
+
+func (s *testServer) deleteMessages(server *tgtest.Server, req *tgtest.Request) error {
+	var r tg.MessagesDeleteMessagesRequest
+	if err := r.Decode(req.Buf); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	kept := s.messages[:0]
+	for _, m := range s.messages {
+		if containsID(r.ID, m.ID) {
+			deleted++
+			continue
+		}
+		kept = append(kept, m)
+	}
+	s.messages = kept
+
+	return server.SendResult(req, &tg.MessagesAffectedMessages{Pts: 1, PtsCount: deleted})
+}
+
+func (s *testServer) getFile(server *tgtest.Server, req *tgtest.Request) error {
+	var r tg.UploadGetFileRequest
+	if err := r.Decode(req.Buf); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.getFileCalls++
+
+	var (
+		id        int64
+		reference []byte
+	)
+	switch loc := r.Location.(type) {
+	case *tg.InputPhotoFileLocation:
+		id, reference = loc.ID, loc.FileReference
+		s.thumbs = append(s.thumbs, loc.ThumbSize)
+	case *tg.InputDocumentFileLocation:
+		id, reference = loc.ID, loc.FileReference
+	default:
+		return server.SendErr(req, tgerr.New(400, "LOCATION_INVALID"))
+	}
+
+	data, ok := s.files[id]
+	if !ok {
+		return server.SendErr(req, tgerr.New(400, "LOCATION_INVALID"))
+	}
+
+	if at, ok := s.expireAt[id]; ok && r.Offset >= at {
+		delete(s.expireAt, id)
+		s.reference++
+	}
+	if len(reference) != 1 || reference[0] != s.reference {
+		return server.SendErr(req, tgerr.New(400, tg.ErrFileReferenceExpired))
+	}
+
+	start := min(int(r.Offset), len(data))
+	end := min(start+r.Limit, len(data))
+
+	return server.SendResult(req, &tg.UploadFile{
+		Type:  &tg.StorageFileUnknown{},
+		Bytes: data[start:end],
+	})
+}
+
+func (s *testServer) initTakeout(server *tgtest.Server, req *tgtest.Request) error {
+	var r tg.AccountInitTakeoutSessionRequest
+	if err := r.Decode(req.Buf); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case s.takeoutDelays < 0:
+		return server.SendErr(req, tgerr.New(420, "TAKEOUT_INIT_DELAY_86400"))
+	case s.takeoutDelays > 0:
+		s.takeoutDelays--
+		return server.SendErr(req, tgerr.New(420, "TAKEOUT_INIT_DELAY_86400"))
+	}
+	return server.SendResult(req, &tg.AccountTakeout{ID: testTakeoutID})
+}
+
+func (s *testServer) finishTakeout(server *tgtest.Server, req *tgtest.Request) error {
+	var r tg.AccountFinishTakeoutSessionRequest
+	if err := r.Decode(req.Buf); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.takeoutFinished = append(s.takeoutFinished, r.Success)
+
+	return server.SendBool(req, true)
+}
+
+// invokeWithTakeout unwraps a request made in a takeout session and hands
+// it to the dispatcher. tgtest only unwraps the connection-level wrappers.
+func (s *testServer) invokeWithTakeout(d *tgtest.Dispatcher, server *tgtest.Server, req *tgtest.Request) error {
+	if err := req.Buf.ConsumeID(tg.InvokeWithTakeoutRequestTypeID); err != nil {
+		return err
+	}
+	id, err := req.Buf.Long()
+	if err != nil {
+		return err
+	}
+	if id != testTakeoutID {
+		return server.SendErr(req, tgerr.New(400, tg.ErrTakeoutInvalid))
+	}
+
+	typeID, err := req.Buf.PeekID()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.takeoutRequests[typeID]++
+	s.mu.Unlock()
+
+	return d.OnMessage(server, req)
+}
+
+// withReference returns a copy of m whose media carries the current file
+// reference. The caller must hold s.mu.
+func (s *testServer) withReference(m *tg.Message) *tg.Message {
+	ref := []byte{s.reference}
+
+	out := *m
+	switch media := m.Media.(type) {
+	case *tg.MessageMediaPhoto:
+		if photo, ok := media.Photo.(*tg.Photo); ok {
+			p := *photo
+			p.FileReference = ref
+			out.Media = &tg.MessageMediaPhoto{Photo: &p}
+		}
+	case *tg.MessageMediaDocument:
+		if doc, ok := media.Document.(*tg.Document); ok {
+			d := *doc
+			d.FileReference = ref
+			out.Media = &tg.MessageMediaDocument{Document: &d}
+		}
+	}
+	return &out
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// startClient starts the server and a client logged in to it, and returns
+// the client once it is ready. Both are stopped when the test ends.
+func startClient(t *testing.T, s *testServer, opts Options) *Client {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	serverDone := make(chan error, 1)
+	go func() { serverDone <- s.cluster.Up(ctx) }()
+
+	select {
+	case <-s.cluster.Ready():
+	case err := <-serverDone:
+		cancel()
+		t.Fatalf("test server failed to start: %v", err)
+	}
+
+	opts.AppID = 1
+	opts.AppHash = "hash"
+	opts.SessionDir = t.TempDir()
+	opts.NonInteractive = true
+	opts.telegram = telegram.Options{
+		PublicKeys: s.cluster.Keys(),
+		Resolver:   s.cluster.Resolver(),
+		DCList:     s.cluster.List(),
+	}
+
+	c, err := NewClient(DefaultAccount, opts)
+	if err != nil {
+		cancel()
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	ready := make(chan struct{})
+	clientDone := make(chan error, 1)
+	go func() {
+		clientDone <- c.StartAndListen(ctx, func(ctx context.Context) error {
+			close(ready)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-clientDone
+		<-serverDone
+	})
+
+	select {
+	case <-ready:
+	case err := <-clientDone:
+		t.Fatalf("client stopped before it was ready: %v", err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("timed out waiting for the client to log in")
+	}
+
+	return c
+}
+
+// testContext returns a context bounding a test's calls to the client.
+func testContext(t *testing.T) context.Context {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// textMessage is a plain text message.
+func textMessage(id int, text string) *tg.Message {
+	return &tg.Message{ID: id, Message: text}
+}
+
+// photoMessage is a message with a photo that has the given sizes.
+func photoMessage(id int, photoID int64, sizes ...tg.PhotoSizeClass) *tg.Message {
+	return &tg.Message{
+		ID: id,
+		Media: &tg.MessageMediaPhoto{Photo: &tg.Photo{
+			ID:         photoID,
+			AccessHash: photoID,
+			Sizes:      sizes,
+			DCID:       2,
+		}},
+	}
+}
+
+// documentMessage is a message with a document.
+func documentMessage(id int, docID int64, mimeType string, size int64) *tg.Message {
+	return &tg.Message{
+		ID: id,
+		Media: &tg.MessageMediaDocument{Document: &tg.Document{
+			ID:         docID,
+			AccessHash: docID,
+			MimeType:   mimeType,
+			Size:       size,
+			DCID:       2,
+		}},
+	}
+}
+
+// inAlbum puts the messages in the album with the grouped ID.
+func inAlbum(groupedID int64, msgs ...*tg.Message) []*tg.Message {
+	for _, m := range msgs {
+		m.GroupedID = groupedID
+	}
+	return msgs
+}
+
+// photoSizes returns plain photo sizes of the given types.
+func photoSizes(types ...string) []tg.PhotoSizeClass {
+	sizes := make([]tg.PhotoSizeClass, len(types))
+	for i, typ := range types {
+		sizes[i] = &tg.PhotoSize{Type: typ, W: 100 * (i + 1), H: 100 * (i + 1), Size: 1000 * (i + 1)}
+	}
+	return sizes
+}
@@ -0,0 +1,131 @@
+package tg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// historyBatchSize is the page size used when walking the whole history.
+// 100 is the maximum accepted by messages.getHistory.
+const historyBatchSize = 100
+
+// historyPeers holds the users and chats returned alongside history pages,
+// so that peers referenced by messages (e.g. forward sources) can be named.
+type historyPeers struct {
+	users map[int64]*tg.User
+	chats map[int64]string
+}
+
+func newHistoryPeers() *historyPeers {
+	return &historyPeers{
+		users: map[int64]*tg.User{},
+		chats: map[int64]string{},
+	}
+}
+
+func (p *historyPeers) add(users []tg.UserClass, chats []tg.ChatClass) {
+	for _, u := range users {
+		if user, ok := u.(*tg.User); ok {
+			p.users[user.ID] = user
+		}
+	}
+	for _, c := range chats {
+		switch chat := c.(type) {
+		case *tg.Chat:
+			p.chats[chat.ID] = chat.Title
+		case *tg.Channel:
+			p.chats[chat.ID] = chat.Title
+		case *tg.ChatForbidden:
+			p.chats[chat.ID] = chat.Title
+		case *tg.ChannelForbidden:
+			p.chats[chat.ID] = chat.Title
+		}
+	}
+}
+
+// name returns a human readable name for peer, or "" if it is unknown.
+func (p *historyPeers) name(peer tg.PeerClass) string {
+	switch v := peer.(type) {
+	case *tg.PeerUser:
+		u, ok := p.users[v.UserID]
+		if !ok {
+			return ""
+		}
+		name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+		if name == "" && u.Username != "" {
+			name = "@" + u.Username
+		}
+		return name
+	case *tg.PeerChat:
+		return p.chats[v.ChatID]
+	case *tg.PeerChannel:
+		return p.chats[v.ChannelID]
+	}
+	return ""
+}
+
+// walkHistory iterates over the whole Saved Messages history, newest first,
+// calling fn for every regular message. Iteration stops at the first error
+// returned by fn.
+func (c *Client) walkHistory(ctx context.Context, fn func(m *tg.Message, peers *historyPeers) error) error {
+	api, err := c.rpc()
+	if err != nil {
+		return err
+	}
+	return walkHistoryWith(ctx, api, fn)
+}
+
+// walkHistoryWith is walkHistory on a given API client, e.g. one that
+// wraps every request in a takeout session.
+func walkHistoryWith(ctx context.Context, api *tg.Client, fn func(m *tg.Message, peers *historyPeers) error) error {
+	peers := newHistoryPeers()
+	offsetID := 0
+	for {
+		history, err := api.MessagesGetHistory(ctx, &tg.MessagesGetHistoryRequest{
+			Peer:     &tg.InputPeerSelf{},
+			OffsetID: offsetID,
+			Limit:    historyBatchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get history at offset %d: %w", offsetID, err)
+		}
+
+		var messages []tg.MessageClass
+		complete := false
+		switch h := history.(type) {
+		case *tg.MessagesMessages:
+			// Not a slice: the whole history fit into this response.
+			messages = h.Messages
+			peers.add(h.Users, h.Chats)
+			complete = true
+		case *tg.MessagesMessagesSlice:
+			messages = h.Messages
+			peers.add(h.Users, h.Chats)
+		case *tg.MessagesChannelMessages:
+			messages = h.Messages
+			peers.add(h.Users, h.Chats)
+		default:
+			return fmt.Errorf("unexpected history type: %T", history)
+		}
+
+		if len(messages) == 0 {
+			return nil
+		}
+
+		for _, msg := range messages {
+			if m, ok := msg.(*tg.Message); ok {
+				if err := fn(m, peers); err != nil {
+					return err
+				}
+			}
+		}
+
+		if complete {
+			return nil
+		}
+		offsetID = messages[len(messages)-1].GetID()
+	}
+}
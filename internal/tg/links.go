@@ -0,0 +1,204 @@
+package tg
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+)
+
+// Link is a single entry of the link library: one unique URL together with
+// every Saved Message it appears in.
+type Link struct {
+	URL         string `json:"url"`
+	Domain      string `json:"domain"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+	FirstSaved  int    `json:"first_saved"` // Unix time of the oldest message containing the link
+	LastSaved   int    `json:"last_saved"`  // Unix time of the newest message containing the link
+	MessageIDs  []int  `json:"message_ids"`
+}
+
+// DomainFacet is the number of unique links saved from a domain.
+type DomainFacet struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+// GetLinks walks the whole Saved Messages history and builds a deduplicated
+// link library, sorted by the date the link was last saved (newest first).
+func (c *Client) GetLinks(ctx context.Context) ([]Link, error) {
+	byURL := map[string]*Link{}
+
+	err := c.walkHistory(ctx, func(m *tg.Message, _ *historyPeers) error {
+		for _, found := range extractLinks(m) {
+			link, ok := byURL[found.URL]
+			if !ok {
+				link = &Link{
+					URL:        found.URL,
+					Domain:     found.Domain,
+					FirstSaved: m.Date,
+					LastSaved:  m.Date,
+				}
+				byURL[found.URL] = link
+			}
+
+			// The first message with a preview wins; later ones only fill gaps
+			if link.Title == "" {
+				link.Title = found.Title
+			}
+			if link.Description == "" {
+				link.Description = found.Description
+			}
+			if link.SiteName == "" {
+				link.SiteName = found.SiteName
+			}
+			if m.Date < link.FirstSaved {
+				link.FirstSaved = m.Date
+			}
+			if m.Date > link.LastSaved {
+				link.LastSaved = m.Date
+			}
+			if !slices.Contains(link.MessageIDs, m.ID) {
+				link.MessageIDs = append(link.MessageIDs, m.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]Link, 0, len(byURL))
+	for _, link := range byURL {
+		links = append(links, *link)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].LastSaved != links[j].LastSaved {
+			return links[i].LastSaved > links[j].LastSaved
+		}
+		return links[i].URL < links[j].URL
+	})
+
+	return links, nil
+}
+
+// LinkDomains counts links per domain, most common first.
+func LinkDomains(links []Link) []DomainFacet {
+	counts := map[string]int{}
+	for _, link := range links {
+		counts[link.Domain]++
+	}
+
+	facets := make([]DomainFacet, 0, len(counts))
+	for domain, count := range counts {
+		facets = append(facets, DomainFacet{Domain: domain, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Domain < facets[j].Domain
+	})
+
+	return facets
+}
+
+// extractedLink is a link found in a single message.
+type extractedLink struct {
+	URL         string
+	Domain      string
+	Title       string
+	Description string
+	SiteName    string
+}
+
+// extractLinks returns the normalized links of a message, taken from URL and
+// text URL entities as well as from the web page preview, without duplicates.
+func extractLinks(m *tg.Message) []extractedLink {
+	var raw []string
+	for _, e := range m.Entities {
+		switch entity := e.(type) {
+		case *tg.MessageEntityURL:
+			raw = append(raw, entityText(m.Message, entity.Offset, entity.Length))
+		case *tg.MessageEntityTextURL:
+			raw = append(raw, entity.URL)
+		}
+	}
+
+	var preview *tg.WebPage
+	var normalizedPreview string
+	if media, ok := m.Media.(*tg.MessageMediaWebPage); ok {
+		if wp, ok := media.Webpage.(*tg.WebPage); ok {
+			preview = wp
+			normalizedPreview, _ = normalizeURL(wp.URL)
+			raw = append(raw, wp.URL)
+		}
+	}
+
+	var result []extractedLink
+	seen := map[string]bool{}
+	for _, r := range raw {
+		u, domain := normalizeURL(r)
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+
+		link := extractedLink{URL: u, Domain: domain}
+		if preview != nil && u == normalizedPreview {
+			link.Title = preview.Title
+			link.Description = preview.Description
+			link.SiteName = preview.SiteName
+		}
+		result = append(result, link)
+	}
+
+	return result
+}
+
+// entityText returns the part of text covered by an entity.
+// Entity offsets and lengths are measured in UTF-16 code units.
+func entityText(text string, offset, length int) string {
+	units := utf16.Encode([]rune(text))
+	if offset < 0 || length <= 0 || offset+length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[offset : offset+length]))
+}
+
+// normalizeURL canonicalizes a URL for deduplication: adds a missing scheme,
+// lowercases scheme and host and drops the fragment. It returns the URL and
+// its domain (without a leading "www."), or empty strings if it is invalid
+// or not http(s): links end up in the UI and in bookmark files, where
+// javascript: and the like would be live.
+func normalizeURL(raw string) (string, string) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ""
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "", ""
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", ""
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	if u.Path == "/" && u.RawQuery == "" {
+		u.Path = ""
+	}
+
+	return u.String(), strings.TrimPrefix(u.Hostname(), "www.")
+}
@@ -0,0 +1,24 @@
+package tg
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		raw, url, domain string
+	}{
+		{"example.com", "http://example.com", "example.com"},
+		{"HTTPS://WWW.Example.com/a?b=1#frag", "https://www.example.com/a?b=1", "example.com"},
+		{"https://example.com/", "https://example.com", "example.com"},
+		{"javascript://x%0Aalert(1)", "", ""},
+		{"ftp://example.com/file", "", ""},
+		{"data://text/html,<script>", "", ""},
+		{"   ", "", ""},
+	}
+
+	for _, tt := range tests {
+		url, domain := normalizeURL(tt.raw)
+		if url != tt.url || domain != tt.domain {
+			t.Errorf("normalizeURL(%q) = %q, %q, want %q, %q", tt.raw, url, domain, tt.url, tt.domain)
+		}
+	}
+}
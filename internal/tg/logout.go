@@ -0,0 +1,68 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gotd/td/telegram/auth"
+)
+
+// Logout terminates the Telegram session on the server (auth.logOut) and
+// wipes the local session data of this account.
+//
+// The local session is removed even when there is no connection to log out
+// with or Telegram no longer accepts the auth key, e.g. because the session
+// was terminated from another device; that is only logged as a warning.
+// Other failures of auth.logOut keep the session, so logging out can be
+// retried.
+func (c *Client) Logout(ctx context.Context) error {
+	api, err := c.rpc()
+	if err == nil {
+		_, err = api.AuthLogOut(ctx)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrNotReady) || auth.IsUnauthorized(err):
+		c.log.Warn("Could not log out on Telegram, removing the local session anyway", "err", err)
+	default:
+		return fmt.Errorf("failed to log out: %w", err)
+	}
+
+	if err := c.removeSession(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.user = nil
+	c.mu.Unlock()
+	return nil
+}
+
+// removeSession deletes this account's session file, temporary files left
+// behind by interrupted writes and, for named accounts, the then empty
+// account directory. Other accounts' sessions in SessionDir are untouched.
+// Cached media of the account is dropped as well.
+func (c *Client) removeSession() error {
+	if err := os.Remove(c.sessionPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove session file: %w", err)
+	}
+
+	// See writeFileAtomic for the pattern
+	leftovers, _ := filepath.Glob(c.sessionPath + ".tmp*")
+	for _, path := range leftovers {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove temporary session file: %w", err)
+		}
+	}
+
+	if c.Account != DefaultAccount {
+		// Fails harmlessly if the user put something else in there
+		os.Remove(filepath.Dir(c.sessionPath))
+	}
+
+	c.mediaCache.clear()
+	return nil
+}
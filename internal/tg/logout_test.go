@@ -0,0 +1,29 @@
+package tg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogoutWithoutConnectionRemovesSession(t *testing.T) {
+	c, err := NewClient("work", Options{AppID: 1, AppHash: "hash", SessionDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.sessionPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.sessionPath, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not connected, as after Telegram refused the revoked auth key
+	if err := c.Logout(context.Background()); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	if _, err := os.Stat(c.sessionPath); !os.IsNotExist(err) {
+		t.Errorf("session file left behind: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package tg
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// mediaCache is a small in-memory LRU cache of downloaded media, bounded by
+// total size in bytes. Entries expire after a TTL so that edited or deleted
+// messages are eventually refetched.
+type mediaCache struct {
+	maxBytes int64
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // Front is most recently used
+	entries map[int]*list.Element
+}
+
+type mediaCacheEntry struct {
+	msgID       int
+	data        []byte
+	contentType string
+	expires     time.Time
+}
+
+// newMediaCache returns a cache holding up to maxBytes of media.
+// A nil cache (maxBytes <= 0) is valid and never stores anything.
+func newMediaCache(maxBytes int64, ttl time.Duration) *mediaCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &mediaCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  map[int]*list.Element{},
+	}
+}
+
+func (c *mediaCache) get(msgID int) ([]byte, string, bool) {
+	if c == nil {
+		return nil, "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[msgID]
+	if !ok {
+		return nil, "", false
+	}
+
+	entry := el.Value.(*mediaCacheEntry)
+	if c.ttl > 0 && time.Now().After(entry.expires) {
+		c.removeLocked(el)
+		return nil, "", false
+	}
+
+	c.order.MoveToFront(el)
+	return entry.data, entry.contentType, true
+}
+
+func (c *mediaCache) put(msgID int, data []byte, contentType string) {
+	// Items bigger than the whole cache would only evict everything else
+	if c == nil || int64(len(data)) > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[msgID]; ok {
+		c.removeLocked(el)
+	}
+
+	entry := &mediaCacheEntry{
+		msgID:       msgID,
+		data:        data,
+		contentType: contentType,
+		expires:     time.Now().Add(c.ttl),
+	}
+	c.entries[msgID] = c.order.PushFront(entry)
+	c.size += int64(len(data))
+
+	for c.size > c.maxBytes {
+		c.removeLocked(c.order.Back())
+	}
+}
+
+// remove drops the given messages, e.g. after they were deleted.
+func (c *mediaCache) remove(msgIDs ...int) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range msgIDs {
+		if el, ok := c.entries[id]; ok {
+			c.removeLocked(el)
+		}
+	}
+}
+
+// clear drops everything, e.g. after logging out.
+func (c *mediaCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.entries)
+	c.size = 0
+}
+
+func (c *mediaCache) removeLocked(el *list.Element) {
+	entry := el.Value.(*mediaCacheEntry)
+	c.order.Remove(el)
+	delete(c.entries, entry.msgID)
+	c.size -= int64(len(entry.data))
+}
@@ -0,0 +1,309 @@
+package tg
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/gotd/td/tg"
+)
+
+// MediaPriority orders queued media downloads.
+type MediaPriority int
+
+const (
+	// PriorityNormal is for prefetching and API clients.
+	PriorityNormal MediaPriority = iota
+	// PriorityHigh is for media on screen, which jumps the queue.
+	PriorityHigh
+)
+
+// ParseMediaPriority parses "normal" (or empty) and "high".
+func ParseMediaPriority(s string) (MediaPriority, error) {
+	switch s {
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return 0, fmt.Errorf("unknown priority %q", s)
+	}
+}
+
+// defaultMediaWorkers is how many media downloads run at once per account
+// when Options.MediaWorkers is not set.
+const defaultMediaWorkers = 4
+
+// mediaScheduler runs media downloads on a bounded number of workers, so a
+// page full of photos does not fire a request per photo at Telegram:
+//
+//   - concurrent requests for the same message share one download;
+//   - queued downloads are served by priority, then in arrival order;
+//   - the messages of queued downloads are looked up in batches, one
+//     messages.getMessages for up to maxLookupBatch of them.
+type mediaScheduler struct {
+	c       *Client
+	workers int
+
+	mu      sync.Mutex
+	jobs    map[int]*mediaJob // Queued or running, by message ID
+	queue   mediaQueue
+	running int // Workers; they exit when the queue is empty
+	seq     uint64
+}
+
+// mediaJob is the download of one message's media and everyone waiting
+// for it.
+type mediaJob struct {
+	msgID    int
+	priority MediaPriority
+	seq      uint64 // Arrival order among equal priorities
+	index    int    // Position in the queue, -1 once a worker took it
+
+	waiters int
+	// ctx is canceled when every waiter has given up, e.g. the browser
+	// scrolled past and aborted the image request.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// source is set by a lookup, possibly one started for another job;
+	// lookup is that lookup while it is in flight.
+	source *mediaSource
+	lookup *mediaLookup
+
+	done        chan struct{}
+	data        []byte
+	contentType string
+	err         error
+}
+
+// mediaLookup is one batched messages.getMessages call.
+type mediaLookup struct {
+	done chan struct{}
+	err  error
+}
+
+func newMediaScheduler(c *Client, workers int) *mediaScheduler {
+	if workers <= 0 {
+		workers = defaultMediaWorkers
+	}
+	return &mediaScheduler{
+		c:       c,
+		workers: workers,
+		jobs:    map[int]*mediaJob{},
+	}
+}
+
+// fetch downloads the media of msgID, joining a download of the same
+// message that is already queued or running.
+func (s *mediaScheduler) fetch(ctx context.Context, msgID int, priority MediaPriority) ([]byte, string, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[msgID]
+	if ok {
+		mediaCoalesced.Inc(s.c.Account)
+		job.waiters++
+		if priority > job.priority && job.index >= 0 {
+			job.priority = priority
+			heap.Fix(&s.queue, job.index)
+		}
+	} else {
+		// The download outlives the request that started it if others
+		// join, so it only keeps the request's values, not its deadline
+		jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		s.seq++
+		job = &mediaJob{
+			msgID:    msgID,
+			priority: priority,
+			seq:      s.seq,
+			waiters:  1,
+			ctx:      jobCtx,
+			cancel:   cancel,
+			done:     make(chan struct{}),
+		}
+		s.jobs[msgID] = job
+		heap.Push(&s.queue, job)
+
+		if s.running < s.workers {
+			s.running++
+			go s.work()
+		}
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-job.done:
+		return job.data, job.contentType, job.err
+	case <-ctx.Done():
+		s.leave(job)
+		return nil, "", ctx.Err()
+	}
+}
+
+// leave drops a waiter. The last one to leave cancels the job.
+func (s *mediaScheduler) leave(job *mediaJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job.waiters--
+	if job.waiters > 0 {
+		return
+	}
+
+	job.cancel()
+	if job.index >= 0 {
+		heap.Remove(&s.queue, job.index)
+	}
+	if s.jobs[job.msgID] == job {
+		delete(s.jobs, job.msgID)
+	}
+}
+
+// work runs queued jobs until the queue is empty.
+func (s *mediaScheduler) work() {
+	for {
+		s.mu.Lock()
+		if s.queue.Len() == 0 {
+			s.running--
+			s.mu.Unlock()
+			return
+		}
+		job := heap.Pop(&s.queue).(*mediaJob)
+		s.mu.Unlock()
+
+		data, contentType, err := s.download(job)
+
+		s.mu.Lock()
+		if s.jobs[job.msgID] == job {
+			delete(s.jobs, job.msgID)
+		}
+		s.mu.Unlock()
+
+		job.data, job.contentType, job.err = data, contentType, err
+		close(job.done)
+		job.cancel()
+	}
+}
+
+func (s *mediaScheduler) download(job *mediaJob) ([]byte, string, error) {
+	api, err := s.c.rpc()
+	if err != nil {
+		return nil, "", err
+	}
+
+	src, err := s.resolve(job.ctx, api, job)
+	if err != nil {
+		return nil, "", err
+	}
+	if src.err != nil {
+		return nil, "", src.err
+	}
+
+	data, err := s.c.downloadMedia(job.ctx, api, job.msgID, src)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, src.contentType, nil
+}
+
+// resolve returns where to download the job's media from. The first worker
+// needing a source looks up its job together with the other queued jobs
+// that lack one; their workers then find the source already there.
+func (s *mediaScheduler) resolve(ctx context.Context, api *tg.Client, job *mediaJob) (mediaSource, error) {
+	for {
+		s.mu.Lock()
+		if job.source != nil {
+			src := *job.source
+			s.mu.Unlock()
+			return src, nil
+		}
+
+		if l := job.lookup; l != nil {
+			// Another worker is looking this message up already
+			s.mu.Unlock()
+			select {
+			case <-l.done:
+			case <-ctx.Done():
+				return mediaSource{}, ctx.Err()
+			}
+			// A lookup abandoned by its own job is retried, anything
+			// else (e.g. a flood wait) is shared by the whole batch
+			if l.err != nil && !errors.Is(l.err, context.Canceled) {
+				return mediaSource{}, l.err
+			}
+			continue
+		}
+
+		l := &mediaLookup{done: make(chan struct{})}
+		batch := []*mediaJob{job}
+		for _, other := range s.queue {
+			if len(batch) == maxLookupBatch {
+				break
+			}
+			if other.source == nil && other.lookup == nil {
+				batch = append(batch, other)
+			}
+		}
+		ids := make([]int, len(batch))
+		for i, j := range batch {
+			j.lookup = l
+			ids[i] = j.msgID
+		}
+		s.mu.Unlock()
+
+		mediaLookupBatch.Observe(float64(len(ids)))
+		sources, err := s.c.mediaSources(ctx, api, ids)
+
+		s.mu.Lock()
+		for _, j := range batch {
+			j.lookup = nil
+			if err == nil {
+				src := sources[j.msgID]
+				j.source = &src
+			}
+		}
+		s.mu.Unlock()
+
+		l.err = err
+		close(l.done)
+
+		if err != nil {
+			return mediaSource{}, err
+		}
+		return sources[job.msgID], nil
+	}
+}
+
+// mediaQueue is a heap of jobs, highest priority first, then oldest first.
+type mediaQueue []*mediaJob
+
+func (q mediaQueue) Len() int { return len(q) }
+
+func (q mediaQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q mediaQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *mediaQueue) Push(x any) {
+	job := x.(*mediaJob)
+	job.index = len(*q)
+	*q = append(*q, job)
+}
+
+func (q *mediaQueue) Pop() any {
+	old := *q
+	job := old[len(old)-1]
+	old[len(old)-1] = nil
+	job.index = -1
+	*q = old[:len(old)-1]
+	return job
+}
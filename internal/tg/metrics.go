@@ -0,0 +1,86 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+
+	"telegram-manager/internal/metrics"
+)
+
+var (
+	rpcCalls = metrics.NewCounterVec("telegram_manager_rpc_calls_total",
+		"Telegram API calls by method.", "account", "method")
+	rpcErrors = metrics.NewCounterVec("telegram_manager_rpc_errors_total",
+		"Failed Telegram API calls by method and error type (the RPC error type, or \"canceled\" and \"transport\").", "account", "method", "type")
+	rpcDuration = metrics.NewHistogramVec("telegram_manager_rpc_duration_seconds",
+		"Latency of Telegram API calls.", metrics.DefaultBuckets, "method")
+	floodWaits = metrics.NewHistogramVec("telegram_manager_flood_wait_seconds",
+		"FLOOD_WAIT errors by method and the wait Telegram asked for.",
+		[]float64{1, 5, 10, 30, 60, 300, 900, 3600}, "account", "method")
+
+	mediaBytes = metrics.NewCounterVec("telegram_manager_media_downloaded_bytes_total",
+		"Bytes of media downloaded from Telegram (cache hits excluded).", "account")
+	mediaCacheRequests = metrics.NewCounterVec("telegram_manager_media_cache_requests_total",
+		"Media cache lookups by result (hit or miss).", "account", "result")
+	mediaCoalesced = metrics.NewCounterVec("telegram_manager_media_coalesced_total",
+		"Media requests that joined a download of the same message already in progress.", "account")
+	mediaLookupBatch = metrics.NewHistogramVec("telegram_manager_media_lookup_batch_size",
+		"Messages looked up per batched messages.getMessages call for media downloads.",
+		[]float64{1, 2, 5, 10, 20, 50, 100})
+	messagesDeleted = metrics.NewCounterVec("telegram_manager_messages_deleted_total",
+		"Messages deleted.", "account")
+)
+
+func init() {
+	metrics.NewGaugeFunc("telegram_manager_media_cache_hit_ratio",
+		"Share of media cache lookups served from the cache since startup, all accounts together.", func() float64 {
+			hits, misses := mediaCacheRequests.Sum("result", "hit"), mediaCacheRequests.Sum("result", "miss")
+			if hits+misses == 0 {
+				return 0
+			}
+			return hits / (hits + misses)
+		})
+}
+
+// metricsMiddleware counts every API call made on the connection, by its
+// TL method name (e.g. "messages.getHistory").
+func (c *Client) metricsMiddleware() telegram.Middleware {
+	return telegram.MiddlewareFunc(func(next tg.Invoker) telegram.InvokeFunc {
+		return func(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+			method := "unknown"
+			if named, ok := input.(interface{ TypeName() string }); ok {
+				method = named.TypeName()
+			}
+
+			start := time.Now()
+			err := next.Invoke(ctx, input, output)
+			rpcDuration.Observe(time.Since(start).Seconds(), method)
+			rpcCalls.Inc(c.Account, method)
+
+			if err != nil {
+				rpcErrors.Inc(c.Account, method, errorType(err))
+				if d, ok := tgerr.AsFloodWait(err); ok {
+					floodWaits.Observe(d.Seconds(), c.Account, method)
+				}
+			}
+			return err
+		}
+	})
+}
+
+// errorType classifies an API error for the errors metric.
+func errorType(err error) string {
+	if rpcErr, ok := tgerr.As(err); ok {
+		return rpcErr.Type
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return "canceled"
+	}
+	return "transport"
+}
@@ -0,0 +1,294 @@
+package tg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gotd/td/tg"
+)
+
+// Order is the order messages are listed in.
+type Order string
+
+const (
+	OrderDesc Order = "desc" // Newest first
+	OrderAsc  Order = "asc"  // Oldest first
+)
+
+// ParseOrder parses an order name, defaulting to OrderDesc.
+func ParseOrder(s string) (Order, error) {
+	switch Order(s) {
+	case "", OrderDesc:
+		return OrderDesc, nil
+	case OrderAsc:
+		return OrderAsc, nil
+	default:
+		return "", fmt.Errorf("unknown order %q", s)
+	}
+}
+
+// maxAlbumSize is the most messages Telegram puts in one album.
+const maxAlbumSize = 10
+
+// Cursor is a position in the message list. Clients treat it as an opaque
+// string (see Encode and ParseCursor).
+type Cursor struct {
+	Order Order `json:"o"`
+	// Backward cursors page against Order, e.g. towards newer messages
+	// when listing newest first.
+	Backward bool `json:"b,omitempty"`
+	// OffsetID is the ID of the message at the page edge; the page starts
+	// right after it. Zero starts at the beginning of the list, or at
+	// OffsetDate if that is set.
+	OffsetID   int `json:"id,omitempty"`
+	OffsetDate int `json:"d,omitempty"`
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor decodes a cursor made by Encode.
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if c.Order != OrderDesc && c.Order != OrderAsc {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// MessagePage is a page of Saved Messages with cursors to its neighbours.
+// A cursor is empty when there is nothing more in that direction.
+type MessagePage struct {
+	Messages   []SavedMessage `json:"messages"`
+	Total      int            `json:"total"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+}
+
+// GetMessagePage returns up to limit messages starting at cursor, in the
+// cursor's order. Albums are never split between pages: when the limit
+// cuts an album, the rest of it is fetched as well, so a page may hold a
+// few more messages than asked for.
+func (c *Client) GetMessagePage(ctx context.Context, cursor Cursor, limit int) (*MessagePage, error) {
+	if _, err := c.rpc(); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = c.opts.DefaultPageSize
+	}
+	if limit > c.opts.MaxPageSize {
+		limit = c.opts.MaxPageSize
+	}
+
+	// Walking towards older messages is Telegram's natural order
+	older := (cursor.Order == OrderDesc) != cursor.Backward
+
+	raw, total, err := c.historyFrom(ctx, cursor.OffsetID, cursor.OffsetDate, older, limit)
+	if err != nil {
+		return nil, err
+	}
+	// A short page means we reached the end of the history
+	exhausted := len(raw) < limit
+
+	// Cursors always point at album edges, but a date or a deletion since
+	// the cursor was made can still land in the middle of one
+	raw, err = c.completeAlbums(ctx, raw, older, !exhausted)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MessagePage{Total: total}
+	if len(raw) == 0 {
+		page.Messages = []SavedMessage{}
+		// Past the end; going back starts at (and includes) the offset message
+		if cursor.OffsetID != 0 {
+			id := cursor.OffsetID + 1
+			if older {
+				id = cursor.OffsetID - 1
+			}
+			back := Cursor{Order: cursor.Order, Backward: !cursor.Backward, OffsetID: id}.Encode()
+			if cursor.Backward {
+				page.NextCursor = back
+			} else {
+				page.PrevCursor = back
+			}
+		}
+		return page, nil
+	}
+
+	// The edges of the walk: near is where this page starts, far is where
+	// the next one in the same direction would start.
+	near, far := raw[0], raw[len(raw)-1]
+
+	if cursor.Backward {
+		// Walked against the list order, put the messages back in order
+		slices.Reverse(raw)
+	}
+	page.Messages = groupMessages(raw)
+
+	var onward, back string
+	if !exhausted {
+		onward = Cursor{Order: cursor.Order, Backward: cursor.Backward, OffsetID: far.GetID(), OffsetDate: messageDate(far)}.Encode()
+	}
+	if cursor.OffsetID != 0 || cursor.OffsetDate != 0 {
+		back = Cursor{Order: cursor.Order, Backward: !cursor.Backward, OffsetID: near.GetID(), OffsetDate: messageDate(near)}.Encode()
+	}
+
+	if cursor.Backward {
+		page.NextCursor, page.PrevCursor = back, onward
+	} else {
+		page.NextCursor, page.PrevCursor = onward, back
+	}
+
+	return page, nil
+}
+
+// historyFrom fetches up to limit messages next to offsetID (or offsetDate
+// if offsetID is zero), walking towards older or newer messages. The
+// result is in walking order and never includes offsetID itself.
+func (c *Client) historyFrom(ctx context.Context, offsetID, offsetDate int, older bool, limit int) ([]tg.MessageClass, int, error) {
+	req := &tg.MessagesGetHistoryRequest{
+		Peer:       &tg.InputPeerSelf{},
+		OffsetID:   offsetID,
+		OffsetDate: offsetDate,
+		Limit:      limit,
+	}
+
+	if !older {
+		// A negative add_offset moves the window to newer messages. The
+		// window then starts at offset_id itself, so skip past it. Without
+		// any offset this starts at the very first message.
+		req.AddOffset = -limit
+		if offsetID != 0 || offsetDate == 0 {
+			req.OffsetID = offsetID + 1
+		}
+	}
+
+	messages, total, err := c.getHistory(ctx, req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !older {
+		// Telegram always answers newest first
+		slices.Reverse(messages)
+	}
+
+	return messages, total, nil
+}
+
+// completeAlbums makes sure no album is cut at either end of raw (in
+// walking order): members beyond the far end are appended, members before
+// the near end are prepended. far can be false when the walk reached the
+// end of the history and nothing can follow.
+func (c *Client) completeAlbums(ctx context.Context, raw []tg.MessageClass, older bool, far bool) ([]tg.MessageClass, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+
+	if far {
+		rest, err := c.albumMembers(ctx, raw[len(raw)-1], older)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, rest...)
+	}
+
+	before, err := c.albumMembers(ctx, raw[0], !older)
+	if err != nil {
+		return nil, err
+	}
+	if len(before) > 0 {
+		// Fetched walking away from raw, so closest first
+		slices.Reverse(before)
+		raw = append(before, raw...)
+	}
+
+	return raw, nil
+}
+
+// albumMembers returns the members of from's album that lie beyond it in
+// the given direction, closest first. Nil if from is not part of an album.
+func (c *Client) albumMembers(ctx context.Context, from tg.MessageClass, older bool) ([]tg.MessageClass, error) {
+	m, ok := from.(*tg.Message)
+	if !ok || m.GroupedID == 0 {
+		return nil, nil
+	}
+
+	var members []tg.MessageClass
+	last := m
+	for {
+		more, _, err := c.historyFrom(ctx, last.ID, 0, older, maxAlbumSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, msg := range more {
+			next, ok := msg.(*tg.Message)
+			if !ok || next.GroupedID != m.GroupedID {
+				return members, nil
+			}
+			members = append(members, next)
+			last = next
+		}
+
+		// The whole batch belonged to the album, there may be more
+		if len(more) < maxAlbumSize {
+			return members, nil
+		}
+	}
+}
+
+// getHistory runs a messages.getHistory request and returns the messages
+// with the total number of messages in the chat.
+func (c *Client) getHistory(ctx context.Context, req *tg.MessagesGetHistoryRequest) ([]tg.MessageClass, int, error) {
+	api, err := c.rpc()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	history, err := api.MessagesGetHistory(ctx, req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	switch h := history.(type) {
+	case *tg.MessagesMessages:
+		c.log.Debug("Got MessagesMessages", "count", len(h.Messages))
+		return h.Messages, len(h.Messages), nil
+	case *tg.MessagesMessagesSlice:
+		c.log.Debug("Got MessagesMessagesSlice", "count", h.Count, "len", len(h.Messages))
+		return h.Messages, h.Count, nil
+	case *tg.MessagesChannelMessages:
+		c.log.Debug("Got MessagesChannelMessages", "count", h.Count)
+		return h.Messages, h.Count, nil
+	default:
+		return nil, 0, fmt.Errorf("unexpected history type: %T", history)
+	}
+}
+
+func messageDate(msg tg.MessageClass) int {
+	switch m := msg.(type) {
+	case *tg.Message:
+		return m.Date
+	case *tg.MessageService:
+		return m.Date
+	default:
+		return 0
+	}
+}
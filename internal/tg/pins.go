@@ -0,0 +1,63 @@
+package tg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gotd/td/tg"
+)
+
+// maxPinned caps how many pinned messages are fetched at once.
+// Telegram allows far fewer pins per chat in practice.
+const maxPinned = 100
+
+// GetPinnedMessages lists pinned messages in Saved Messages, newest first.
+func (c *Client) GetPinnedMessages(ctx context.Context) ([]SavedMessage, error) {
+	api, err := c.rpc()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := api.MessagesSearch(ctx, &tg.MessagesSearchRequest{
+		Peer:   &tg.InputPeerSelf{},
+		Filter: &tg.InputMessagesFilterPinned{},
+		Limit:  maxPinned,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search pinned messages: %w", err)
+	}
+
+	var messages []tg.MessageClass
+	switch r := res.(type) {
+	case *tg.MessagesMessages:
+		messages = r.Messages
+	case *tg.MessagesMessagesSlice:
+		messages = r.Messages
+	case *tg.MessagesChannelMessages:
+		messages = r.Messages
+	default:
+		return nil, fmt.Errorf("unexpected search result type: %T", res)
+	}
+
+	return groupMessages(messages), nil
+}
+
+// SetPinned pins or unpins a message in Saved Messages.
+func (c *Client) SetPinned(ctx context.Context, id int, pinned bool) error {
+	api, err := c.rpc()
+	if err != nil {
+		return err
+	}
+
+	_, err = api.MessagesUpdatePinnedMessage(ctx, &tg.MessagesUpdatePinnedMessageRequest{
+		Silent: true,
+		Unpin:  !pinned,
+		Peer:   &tg.InputPeerSelf{},
+		ID:     id,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update pin for message %d: %w", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package tg
+
+import (
+	"github.com/gotd/td/tg"
+)
+
+// Poll is a poll or quiz with its current results.
+type Poll struct {
+	Question       string       `json:"question"`
+	Options        []PollOption `json:"options"`
+	TotalVoters    int          `json:"total_voters"`
+	Closed         bool         `json:"closed,omitempty"`
+	Quiz           bool         `json:"quiz,omitempty"`
+	MultipleChoice bool         `json:"multiple_choice,omitempty"`
+	// Solution explains the correct answer of a quiz, once voted.
+	Solution string `json:"solution,omitempty"`
+}
+
+// PollOption is a poll answer and how many voted for it. Results are only
+// known once the user voted or the poll closed.
+type PollOption struct {
+	Text   string `json:"text"`
+	Voters int    `json:"voters"`
+	// Chosen marks the options the user voted for.
+	Chosen bool `json:"chosen,omitempty"`
+	// Correct marks the right answer of a quiz.
+	Correct bool `json:"correct,omitempty"`
+}
+
+// Contact is a shared phone contact.
+type Contact struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Phone     string `json:"phone"`
+	// UserID is the Telegram user of the contact, 0 if not on Telegram.
+	UserID int64 `json:"user_id,omitempty"`
+}
+
+// Location is a point on the map, shared as is, live or as a venue.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	// AccuracyRadius is in meters, 0 if unknown.
+	AccuracyRadius int `json:"accuracy_radius,omitempty"`
+	// Live locations are updated for LivePeriod seconds after the message
+	// date, or until the user stops sharing if LivePeriod is 0.
+	Live       bool `json:"live,omitempty"`
+	LivePeriod int  `json:"live_period,omitempty"`
+}
+
+// Venue is a named place; its coordinates are in the message's Location.
+type Venue struct {
+	Title   string `json:"title"`
+	Address string `json:"address"`
+	// Provider is the venue database, e.g. "foursquare", and VenueID the ID
+	// in it.
+	Provider string `json:"provider,omitempty"`
+	VenueID  string `json:"venue_id,omitempty"`
+}
+
+// Dice is an animated emoji with a random value, e.g. a die roll.
+type Dice struct {
+	Emoticon string `json:"emoticon"`
+	Value    int    `json:"value"`
+}
+
+// liveLocationForever is the period of a live location shared until the
+// user stops it.
+const liveLocationForever = 0x7FFFFFFF
+
+// richMediaType names media other than photos, documents and web pages,
+// "Media" if it is of no known kind.
+func richMediaType(media tg.MessageMediaClass) string {
+	switch media.(type) {
+	case *tg.MessageMediaPoll:
+		return "Poll"
+	case *tg.MessageMediaContact:
+		return "Contact"
+	case *tg.MessageMediaGeo:
+		return "Location"
+	case *tg.MessageMediaGeoLive:
+		return "LiveLocation"
+	case *tg.MessageMediaVenue:
+		return "Venue"
+	case *tg.MessageMediaDice:
+		return "Dice"
+	}
+	return "Media"
+}
+
+// setRichMedia fills the structured fields of the message for the kinds
+// named by richMediaType. Other media is left alone.
+func (s *SavedMessage) setRichMedia(media tg.MessageMediaClass) {
+	switch media := media.(type) {
+	case *tg.MessageMediaPoll:
+		s.Poll = pollOf(media)
+	case *tg.MessageMediaContact:
+		s.Contact = &Contact{
+			FirstName: media.FirstName,
+			LastName:  media.LastName,
+			Phone:     media.PhoneNumber,
+			UserID:    media.UserID,
+		}
+	case *tg.MessageMediaGeo:
+		s.Location = geoLocation(media.Geo)
+	case *tg.MessageMediaGeoLive:
+		s.Location = geoLocation(media.Geo)
+		if s.Location != nil {
+			s.Location.Live = true
+			if media.Period != liveLocationForever {
+				s.Location.LivePeriod = media.Period
+			}
+		}
+	case *tg.MessageMediaVenue:
+		s.Location = geoLocation(media.Geo)
+		s.Venue = &Venue{
+			Title:    media.Title,
+			Address:  media.Address,
+			Provider: media.Provider,
+			VenueID:  media.VenueID,
+		}
+	case *tg.MessageMediaDice:
+		s.Dice = &Dice{Emoticon: media.Emoticon, Value: media.Value}
+	}
+}
+
+// pollOf converts a poll, matching results to answers by their option bytes.
+func pollOf(media *tg.MessageMediaPoll) *Poll {
+	p := &Poll{
+		Question:       media.Poll.Question.Text,
+		Options:        make([]PollOption, len(media.Poll.Answers)),
+		TotalVoters:    media.Results.TotalVoters,
+		Closed:         media.Poll.Closed,
+		Quiz:           media.Poll.Quiz,
+		MultipleChoice: media.Poll.MultipleChoice,
+		Solution:       media.Results.Solution,
+	}
+
+	for i, answer := range media.Poll.Answers {
+		p.Options[i].Text = answer.Text.Text
+		for _, r := range media.Results.Results {
+			if string(r.Option) == string(answer.Option) {
+				p.Options[i].Voters = r.Voters
+				p.Options[i].Chosen = r.Chosen
+				p.Options[i].Correct = r.Correct
+			}
+		}
+	}
+	return p
+}
+
+// geoLocation converts a geo point, nil if it is empty.
+func geoLocation(geo tg.GeoPointClass) *Location {
+	point, ok := geo.(*tg.GeoPoint)
+	if !ok {
+		return nil
+	}
+	return &Location{
+		Latitude:       point.Lat,
+		Longitude:      point.Long,
+		AccuracyRadius: point.AccuracyRadius,
+	}
+}
@@ -0,0 +1,123 @@
+package tg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestRichMedia(t *testing.T) {
+	point := &tg.GeoPoint{Lat: 52.52, Long: 13.405, AccuracyRadius: 20}
+
+	tests := []struct {
+		name      string
+		media     tg.MessageMediaClass
+		mediaType string
+		want      SavedMessage
+	}{
+		{
+			name: "poll",
+			media: &tg.MessageMediaPoll{
+				Poll: tg.Poll{
+					Quiz:     true,
+					Question: tg.TextWithEntities{Text: "Capital of France?"},
+					Answers: []tg.PollAnswer{
+						{Text: tg.TextWithEntities{Text: "Paris"}, Option: []byte{0}},
+						{Text: tg.TextWithEntities{Text: "Lyon"}, Option: []byte{1}},
+					},
+				},
+				Results: tg.PollResults{
+					TotalVoters: 4,
+					Results: []tg.PollAnswerVoters{
+						{Option: []byte{1}, Voters: 1, Chosen: true},
+						{Option: []byte{0}, Voters: 3, Correct: true},
+					},
+					Solution: "It is Paris.",
+				},
+			},
+			mediaType: "Poll",
+			want: SavedMessage{Poll: &Poll{
+				Question: "Capital of France?",
+				Options: []PollOption{
+					{Text: "Paris", Voters: 3, Correct: true},
+					{Text: "Lyon", Voters: 1, Chosen: true},
+				},
+				TotalVoters: 4,
+				Quiz:        true,
+				Solution:    "It is Paris.",
+			}},
+		},
+		{
+			name:      "contact",
+			media:     &tg.MessageMediaContact{FirstName: "Ada", LastName: "Lovelace", PhoneNumber: "+441234", UserID: 5},
+			mediaType: "Contact",
+			want:      SavedMessage{Contact: &Contact{FirstName: "Ada", LastName: "Lovelace", Phone: "+441234", UserID: 5}},
+		},
+		{
+			name:      "location",
+			media:     &tg.MessageMediaGeo{Geo: point},
+			mediaType: "Location",
+			want:      SavedMessage{Location: &Location{Latitude: 52.52, Longitude: 13.405, AccuracyRadius: 20}},
+		},
+		{
+			name:      "empty location",
+			media:     &tg.MessageMediaGeo{Geo: &tg.GeoPointEmpty{}},
+			mediaType: "Location",
+		},
+		{
+			name:      "live location",
+			media:     &tg.MessageMediaGeoLive{Geo: point, Period: 900},
+			mediaType: "LiveLocation",
+			want:      SavedMessage{Location: &Location{Latitude: 52.52, Longitude: 13.405, AccuracyRadius: 20, Live: true, LivePeriod: 900}},
+		},
+		{
+			name:      "indefinite live location",
+			media:     &tg.MessageMediaGeoLive{Geo: point, Period: 0x7FFFFFFF},
+			mediaType: "LiveLocation",
+			want:      SavedMessage{Location: &Location{Latitude: 52.52, Longitude: 13.405, AccuracyRadius: 20, Live: true}},
+		},
+		{
+			name:      "venue",
+			media:     &tg.MessageMediaVenue{Geo: point, Title: "Brandenburger Tor", Address: "Pariser Platz", Provider: "foursquare", VenueID: "abc"},
+			mediaType: "Venue",
+			want: SavedMessage{
+				Location: &Location{Latitude: 52.52, Longitude: 13.405, AccuracyRadius: 20},
+				Venue:    &Venue{Title: "Brandenburger Tor", Address: "Pariser Platz", Provider: "foursquare", VenueID: "abc"},
+			},
+		},
+		{
+			name:      "dice",
+			media:     &tg.MessageMediaDice{Emoticon: "🎲", Value: 6},
+			mediaType: "Dice",
+			want:      SavedMessage{Dice: &Dice{Emoticon: "🎲", Value: 6}},
+		},
+		{
+			name:      "unknown",
+			media:     &tg.MessageMediaUnsupported{},
+			mediaType: "Media",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := groupMessages([]tg.MessageClass{&tg.Message{ID: 1, Media: tt.media}})
+			if len(got) != 1 {
+				t.Fatalf("got %d messages, want 1", len(got))
+			}
+			m := got[0]
+			if m.MediaType != tt.mediaType {
+				t.Errorf("media type = %q, want %q", m.MediaType, tt.mediaType)
+			}
+			if !reflect.DeepEqual(m.Poll, tt.want.Poll) || !reflect.DeepEqual(m.Contact, tt.want.Contact) ||
+				!reflect.DeepEqual(m.Location, tt.want.Location) || !reflect.DeepEqual(m.Venue, tt.want.Venue) ||
+				!reflect.DeepEqual(m.Dice, tt.want.Dice) {
+				t.Errorf("got poll %+v, contact %+v, location %+v, venue %+v, dice %+v",
+					m.Poll, m.Contact, m.Location, m.Venue, m.Dice)
+			}
+			if len(m.Attachments) != 0 {
+				t.Errorf("got attachments %v", m.Attachments)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gotd/td/tg"
+)
+
+// SearchMessages finds Saved Messages containing query, newest first.
+func (c *Client) SearchMessages(ctx context.Context, query string, limit int) ([]SavedMessage, int, error) {
+	api, err := c.rpc()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if strings.TrimSpace(query) == "" {
+		return nil, 0, errors.New("search query is empty")
+	}
+
+	if limit <= 0 {
+		limit = c.opts.DefaultPageSize
+	}
+	if limit > c.opts.MaxPageSize {
+		limit = c.opts.MaxPageSize
+	}
+
+	res, err := api.MessagesSearch(ctx, &tg.MessagesSearchRequest{
+		Peer:   &tg.InputPeerSelf{},
+		Q:      query,
+		Filter: &tg.InputMessagesFilterEmpty{},
+		Limit:  limit,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search messages: %w", err)
+	}
+
+	var messages []tg.MessageClass
+	var total int
+	switch r := res.(type) {
+	case *tg.MessagesMessages:
+		messages = r.Messages
+		total = len(r.Messages)
+	case *tg.MessagesMessagesSlice:
+		messages = r.Messages
+		total = r.Count
+	case *tg.MessagesChannelMessages:
+		messages = r.Messages
+		total = r.Count
+	default:
+		return nil, 0, fmt.Errorf("unexpected search result type: %T", res)
+	}
+
+	return groupMessages(messages), total, nil
+}
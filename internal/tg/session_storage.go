@@ -0,0 +1,219 @@
+package tg
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/gotd/td/session"
+)
+
+const (
+	sessionCipher = "aes-256-gcm"
+	sessionKDF    = "pbkdf2-sha256"
+
+	// sessionKDFIterations follows the current OWASP recommendation for PBKDF2-HMAC-SHA256.
+	sessionKDFIterations = 600_000
+	// maxSessionKDFIterations bounds the iteration count read from a session
+	// file or string, so a tampered one cannot keep the key derivation busy.
+	maxSessionKDFIterations = 10 * sessionKDFIterations
+	sessionSaltSize         = 16
+)
+
+// encryptedSession is the on-disk format of an encrypted session file.
+type encryptedSession struct {
+	Cipher     string `json:"cipher"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedFileStorage implements session.Storage, keeping the session in
+// Path encrypted with AES-256-GCM under a key derived from a secret with
+// PBKDF2. A plain session file found at Path (as written by
+// telegram.FileSessionStorage) is loaded once and re-written encrypted.
+type EncryptedFileStorage struct {
+	Path   string
+	Secret []byte
+
+	mu sync.Mutex
+	// Key derivation is slow on purpose, so the key is cached per salt and
+	// iteration count.
+	salt       []byte
+	iterations int
+	key        []byte
+}
+
+// LoadSession loads and decrypts the session, migrating a plain session file.
+func (s *EncryptedFileStorage) LoadSession(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	raw, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var enc encryptedSession
+	if err := json.Unmarshal(raw, &enc); err != nil || enc.Cipher == "" {
+		// Not our format: a plain session from before encryption was enabled.
+		slog.Info("Encrypting plain session file", "path", s.Path)
+		if err := s.store(raw); err != nil {
+			return nil, fmt.Errorf("failed to migrate plain session: %w", err)
+		}
+		return raw, nil
+	}
+
+	if enc.Cipher != sessionCipher || enc.KDF != sessionKDF {
+		return nil, fmt.Errorf("unsupported session encryption %s/%s", enc.Cipher, enc.KDF)
+	}
+	if enc.Iterations < 1 || enc.Iterations > maxSessionKDFIterations {
+		return nil, fmt.Errorf("invalid session key derivation iteration count %d", enc.Iterations)
+	}
+
+	key, err := s.deriveKey(enc.Salt, enc.Iterations)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := gcm.Open(nil, enc.Nonce, enc.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("failed to decrypt session: wrong passphrase or corrupted file")
+	}
+
+	return data, nil
+}
+
+// StoreSession encrypts and stores the session.
+func (s *EncryptedFileStorage) StoreSession(ctx context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.store(data)
+}
+
+func (s *EncryptedFileStorage) store(data []byte) error {
+	salt := s.salt
+	if salt == nil {
+		salt = make([]byte, sessionSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+
+	key, err := s.deriveKey(salt, sessionKDFIterations)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(encryptedSession{
+		Cipher:     sessionCipher,
+		KDF:        sessionKDF,
+		Iterations: sessionKDFIterations,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, data, nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.Path, raw, 0600)
+}
+
+// deriveKey returns the encryption key for salt and iterations, reusing the
+// cached one if both match.
+func (s *EncryptedFileStorage) deriveKey(salt []byte, iterations int) ([]byte, error) {
+	if len(s.Secret) == 0 {
+		return nil, errors.New("session encryption secret is empty")
+	}
+
+	if s.key != nil && string(s.salt) == string(salt) && s.iterations == iterations {
+		return s.key, nil
+	}
+
+	key, err := pbkdf2.Key(sha256.New, string(s.Secret), salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive session key: %w", err)
+	}
+
+	s.salt, s.iterations, s.key = salt, iterations, key
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so a crash never leaves a truncated session behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// newSessionStorage returns the storage for the session file at path,
+// encrypted if a secret is configured.
+func newSessionStorage(path string, secret []byte) session.Storage {
+	if secret != nil {
+		return &EncryptedFileStorage{Path: path, Secret: secret}
+	}
+	return &session.FileStorage{Path: path}
+}
+
+// sessionStorage returns the storage of this client's session file. It is
+// created once per client, so reconnects reuse the cached encryption key.
+func (c *Client) sessionStorage() session.Storage {
+	return c.storage
+}
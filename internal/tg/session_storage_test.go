@@ -0,0 +1,69 @@
+package tg
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedStorageKeyCacheChecksIterations(t *testing.T) {
+	ctx := context.Background()
+	s := &EncryptedFileStorage{Path: filepath.Join(t.TempDir(), "session.json"), Secret: []byte("secret")}
+
+	if err := s.StoreSession(ctx, []byte(`{"Version":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LoadSession(ctx); err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	// Same salt, different iteration count: the cached key must not be used
+	raw, err := os.ReadFile(s.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var enc encryptedSession
+	if err := json.Unmarshal(raw, &enc); err != nil {
+		t.Fatal(err)
+	}
+	enc.Iterations++
+	writeEncryptedSession(t, s.Path, enc)
+	if _, err := s.LoadSession(ctx); err == nil {
+		t.Error("session with a tampered iteration count decrypted with the cached key")
+	}
+
+	// An absurd iteration count is refused before deriving a key
+	for _, iterations := range []int{0, maxSessionKDFIterations + 1, 1 << 40} {
+		enc.Iterations = iterations
+		writeEncryptedSession(t, s.Path, enc)
+		if _, err := s.LoadSession(ctx); err == nil || !strings.Contains(err.Error(), "iteration count") {
+			t.Errorf("%d iterations: err = %v, want the count refused", iterations, err)
+		}
+	}
+}
+
+func writeEncryptedSession(t *testing.T, path string, enc encryptedSession) {
+	t.Helper()
+	raw, err := json.Marshal(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionStorageReusedAcrossConnections(t *testing.T) {
+	c, err := NewClient(DefaultAccount, Options{AppID: 1, AppHash: "hash", SessionDir: t.TempDir(), SessionSecret: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// StartAndListen asks for the storage on every (re)connect; a new one
+	// would derive the key again
+	if c.sessionStorage() != c.sessionStorage() {
+		t.Error("session storage is created anew for every connection")
+	}
+}
@@ -0,0 +1,188 @@
+package tg
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotd/td/session"
+)
+
+// sessionTransferPrefix starts every exported session string, so a
+// truncated copy or some other token is recognized before decrypting.
+const sessionTransferPrefix = "tgm-session-1:"
+
+// MinTransferPassphrase is the shortest passphrase accepted for exported
+// sessions. The string grants full access to the account, and unlike the
+// session file it is meant to be copied around.
+const MinTransferPassphrase = 8
+
+// ErrShortPassphrase is returned by ExportSession for passphrases shorter
+// than MinTransferPassphrase.
+var ErrShortPassphrase = fmt.Errorf("passphrase must be at least %d characters", MinTransferPassphrase)
+
+// ErrSessionExists is returned by ImportSession when the account already
+// has a session and replacing it was not asked for.
+var ErrSessionExists = errors.New("account already has a session")
+
+// ErrInvalidSession is returned by ImportSession when the string cannot be
+// decrypted or does not hold a usable session.
+var ErrInvalidSession = errors.New("invalid exported session")
+
+// ErrNoSession is returned by ExportSession when the account has never
+// logged in.
+var ErrNoSession = errors.New("account has no session")
+
+// ExportSession returns the account's session as a portable string,
+// encrypted with passphrase. It can be imported into another install with
+// ImportSession; the local session is left as it is.
+func (c *Client) ExportSession(ctx context.Context, passphrase string) (string, error) {
+	if len(passphrase) < MinTransferPassphrase {
+		return "", ErrShortPassphrase
+	}
+
+	data, err := c.sessionStorage().LoadSession(ctx)
+	if errors.Is(err, session.ErrNotFound) {
+		return "", ErrNoSession
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load session: %w", err)
+	}
+
+	if err := checkSession(ctx, data); err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, sessionSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, sessionKDFIterations, 32)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	// Same envelope as an encrypted session file, but under the transfer
+	// passphrase rather than the local session secret
+	raw, err := json.Marshal(encryptedSession{
+		Cipher:     sessionCipher,
+		KDF:        sessionKDF,
+		Iterations: sessionKDFIterations,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, data, []byte(sessionTransferPrefix)),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return sessionTransferPrefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// ImportSession decrypts a string made by ExportSession and stores it as
+// the account's session, encrypted with the local session secret if one is
+// configured. An existing session is only overwritten if replace is set.
+// A connected client keeps using its old session until Reconnect.
+func (c *Client) ImportSession(ctx context.Context, exported, passphrase string, replace bool) error {
+	data, err := decodeSession(exported, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := checkSession(ctx, data); err != nil {
+		return err
+	}
+
+	if !replace {
+		if _, err := os.Stat(c.sessionPath); err == nil {
+			return ErrSessionExists
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.sessionPath), 0700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	if err := c.sessionStorage().StoreSession(ctx, data); err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+
+	c.log.Info("Imported session", "path", c.sessionPath)
+	return nil
+}
+
+// decodeSession reverses the encoding and encryption of ExportSession.
+func decodeSession(exported, passphrase string) ([]byte, error) {
+	exported = strings.TrimSpace(exported)
+	if !strings.HasPrefix(exported, sessionTransferPrefix) {
+		return nil, fmt.Errorf("%w: not an exported session", ErrInvalidSession)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(exported, sessionTransferPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("%w: string is corrupted", ErrInvalidSession)
+	}
+
+	var enc encryptedSession
+	if err := json.Unmarshal(raw, &enc); err != nil {
+		return nil, fmt.Errorf("%w: string is corrupted", ErrInvalidSession)
+	}
+	if enc.Cipher != sessionCipher || enc.KDF != sessionKDF {
+		return nil, fmt.Errorf("%w: unsupported encryption %s/%s", ErrInvalidSession, enc.Cipher, enc.KDF)
+	}
+	if enc.Iterations < 1 || enc.Iterations > maxSessionKDFIterations {
+		return nil, fmt.Errorf("%w: string is corrupted", ErrInvalidSession)
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, enc.Salt, enc.Iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(enc.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: string is corrupted", ErrInvalidSession)
+	}
+
+	data, err := gcm.Open(nil, enc.Nonce, enc.Ciphertext, []byte(sessionTransferPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("%w: wrong passphrase or corrupted string", ErrInvalidSession)
+	}
+	return data, nil
+}
+
+// checkSession makes sure data is a gotd session with an auth key, i.e.
+// one that can log in.
+func checkSession(ctx context.Context, data []byte) error {
+	storage := &session.StorageMemory{}
+	if err := storage.StoreSession(ctx, data); err != nil {
+		return err
+	}
+
+	loaded, err := (&session.Loader{Storage: storage}).Load(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSession, err)
+	}
+	if len(loaded.AuthKey) == 0 {
+		return fmt.Errorf("%w: no auth key", ErrInvalidSession)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package tg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gotd/td/session"
+)
+
+func TestSessionExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	source, err := NewClient("source", Options{AppID: 1, AppHash: "hash", SessionDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.ExportSession(ctx, "long enough"); !errors.Is(err, ErrNoSession) {
+		t.Fatalf("export without a session: err = %v, want ErrNoSession", err)
+	}
+
+	want := &session.Data{DC: 2, Addr: "149.154.167.50:443", AuthKey: bytes.Repeat([]byte{7}, 256), AuthKeyID: []byte{1, 2, 3, 4, 5, 6, 7, 8}}
+	if err := os.MkdirAll(filepath.Dir(source.sessionPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&session.Loader{Storage: source.sessionStorage()}).Save(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.ExportSession(ctx, "short"); !errors.Is(err, ErrShortPassphrase) {
+		t.Errorf("short passphrase: err = %v, want ErrShortPassphrase", err)
+	}
+	exported, err := source.ExportSession(ctx, "correct horse")
+	if err != nil {
+		t.Fatalf("ExportSession failed: %v", err)
+	}
+
+	// The new install encrypts its sessions at rest
+	target, err := NewClient("target", Options{AppID: 1, AppHash: "hash", SessionDir: t.TempDir(), SessionSecret: []byte("local secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := target.ImportSession(ctx, exported, "wrong horse", false); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("wrong passphrase: err = %v, want ErrInvalidSession", err)
+	}
+	if err := target.ImportSession(ctx, exported[:len(exported)-10], "correct horse", false); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("truncated string: err = %v, want ErrInvalidSession", err)
+	}
+
+	if err := target.ImportSession(ctx, exported, "correct horse", false); err != nil {
+		t.Fatalf("ImportSession failed: %v", err)
+	}
+	got, err := (&session.Loader{Storage: target.sessionStorage()}).Load(ctx)
+	if err != nil {
+		t.Fatalf("imported session does not load: %v", err)
+	}
+	if got.DC != want.DC || got.Addr != want.Addr || !bytes.Equal(got.AuthKey, want.AuthKey) {
+		t.Errorf("imported session = %+v, want %+v", got, want)
+	}
+
+	if err := target.ImportSession(ctx, exported, "correct horse", false); !errors.Is(err, ErrSessionExists) {
+		t.Errorf("import over a session: err = %v, want ErrSessionExists", err)
+	}
+	if err := target.ImportSession(ctx, exported, "correct horse", true); err != nil {
+		t.Errorf("import with replace failed: %v", err)
+	}
+
+	if err := target.removeSession(); err != nil {
+		t.Fatalf("removeSession failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(target.sessionPath)); !os.IsNotExist(err) {
+		t.Errorf("account directory left behind after removing the session: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package tg
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// StatBucket aggregates the messages sharing a key (media type, month, ...).
+type StatBucket struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Bytes int64  `json:"bytes"`
+}
+
+// FileInfo describes a single file stored in Saved Messages.
+type FileInfo struct {
+	MessageID int    `json:"message_id"`
+	Date      int    `json:"date"`
+	MediaType string `json:"media_type"`
+	Name      string `json:"name,omitempty"`
+	MimeType  string `json:"mime_type,omitempty"`
+	Size      int64  `json:"size"`
+}
+
+// Stats is a storage usage breakdown of Saved Messages.
+type Stats struct {
+	TotalMessages   int          `json:"total_messages"`
+	TotalFiles      int          `json:"total_files"`
+	TotalBytes      int64        `json:"total_bytes"`
+	ByMediaType     []StatBucket `json:"by_media_type"`
+	ByMonth         []StatBucket `json:"by_month"` // Chronological, "YYYY-MM"
+	ByForwardSource []StatBucket `json:"by_forward_source"`
+	ByExtension     []StatBucket `json:"by_extension"`
+	LargestFiles    []FileInfo   `json:"largest_files"`
+}
+
+// GetStats walks the whole Saved Messages history and aggregates message
+// counts and media sizes. Up to top of the largest files are listed.
+func (c *Client) GetStats(ctx context.Context, top int) (*Stats, error) {
+	stats := &Stats{}
+	byMediaType := map[string]*StatBucket{}
+	byMonth := map[string]*StatBucket{}
+	byForwardSource := map[string]*StatBucket{}
+	byExtension := map[string]*StatBucket{}
+	var files []FileInfo
+
+	err := c.walkHistory(ctx, func(m *tg.Message, peers *historyPeers) error {
+		file := messageFile(m)
+
+		stats.TotalMessages++
+		stats.TotalBytes += file.Size
+
+		addToBucket(byMediaType, file.MediaType, file.Size)
+		addToBucket(byMonth, time.Unix(int64(m.Date), 0).UTC().Format("2006-01"), file.Size)
+
+		if fwd, ok := m.GetFwdFrom(); ok {
+			addToBucket(byForwardSource, forwardSource(fwd, peers), file.Size)
+		}
+
+		if file.Size > 0 {
+			stats.TotalFiles++
+			ext := strings.ToLower(path.Ext(file.Name))
+			if ext == "" {
+				ext = "(none)"
+			}
+			addToBucket(byExtension, ext, file.Size)
+			files = append(files, file)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats.ByMediaType = sortedBuckets(byMediaType)
+	stats.ByForwardSource = sortedBuckets(byForwardSource)
+	stats.ByExtension = sortedBuckets(byExtension)
+
+	stats.ByMonth = sortedBuckets(byMonth)
+	sort.Slice(stats.ByMonth, func(i, j int) bool {
+		return stats.ByMonth[i].Key < stats.ByMonth[j].Key
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Size > files[j].Size
+	})
+	if len(files) > top {
+		files = files[:top]
+	}
+	stats.LargestFiles = append([]FileInfo{}, files...)
+
+	return stats, nil
+}
+
+// messageFile describes the media of a message. Messages without media get
+// the "Text" media type and zero size.
+func messageFile(m *tg.Message) FileInfo {
+	file := FileInfo{
+		MessageID: m.ID,
+		Date:      m.Date,
+		MediaType: "Text",
+	}
+
+	switch media := m.Media.(type) {
+	case nil:
+	case *tg.MessageMediaPhoto:
+		file.MediaType = "Photo"
+		if photo, ok := media.Photo.(*tg.Photo); ok {
+			file.Name = "photo.jpg"
+			file.MimeType = "image/jpeg"
+			file.Size = largestPhotoSize(photo)
+		}
+	case *tg.MessageMediaDocument:
+		file.MediaType = "Document"
+		if doc, ok := media.Document.(*tg.Document); ok {
+			file.MediaType = documentKind(doc)
+			file.MimeType = doc.MimeType
+			file.Size = doc.Size
+			for _, attr := range doc.Attributes {
+				if fn, ok := attr.(*tg.DocumentAttributeFilename); ok {
+					file.Name = fn.FileName
+				}
+			}
+		}
+	case *tg.MessageMediaWebPage:
+		file.MediaType = "WebLink"
+	default:
+		file.MediaType = richMediaType(media)
+	}
+
+	return file
+}
+
+// documentKind refines the "Document" media type using document attributes.
+func documentKind(doc *tg.Document) string {
+	kind := "Document"
+	for _, attr := range doc.Attributes {
+		switch a := attr.(type) {
+		case *tg.DocumentAttributeSticker:
+			return "Sticker"
+		case *tg.DocumentAttributeAnimated:
+			return "GIF"
+		case *tg.DocumentAttributeVideo:
+			if a.RoundMessage {
+				return "Video Message"
+			}
+			kind = "Video"
+		case *tg.DocumentAttributeAudio:
+			if a.Voice {
+				return "Voice"
+			}
+			kind = "Audio"
+		}
+	}
+	return kind
+}
+
+// largestPhotoSize returns the size in bytes of the biggest stored variant of a photo.
+func largestPhotoSize(photo *tg.Photo) int64 {
+	var best int64
+	for _, s := range photo.Sizes {
+		switch sz := s.(type) {
+		case *tg.PhotoSize:
+			best = max(best, int64(sz.Size))
+		case *tg.PhotoSizeProgressive:
+			for _, v := range sz.Sizes {
+				best = max(best, int64(v))
+			}
+		}
+	}
+	return best
+}
+
+// forwardSource names the original sender of a forwarded message.
+func forwardSource(fwd tg.MessageFwdHeader, peers *historyPeers) string {
+	if from, ok := fwd.GetFromID(); ok {
+		if name := peers.name(from); name != "" {
+			return name
+		}
+	}
+	if fwd.FromName != "" {
+		return fwd.FromName
+	}
+	return "Unknown"
+}
+
+func addToBucket(buckets map[string]*StatBucket, key string, size int64) {
+	b, ok := buckets[key]
+	if !ok {
+		b = &StatBucket{Key: key}
+		buckets[key] = b
+	}
+	b.Count++
+	b.Bytes += size
+}
+
+// sortedBuckets flattens buckets, biggest (by bytes, then count) first.
+func sortedBuckets(buckets map[string]*StatBucket) []StatBucket {
+	result := make([]StatBucket, 0, len(buckets))
+	for _, b := range buckets {
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bytes != result[j].Bytes {
+			return result[i].Bytes > result[j].Bytes
+		}
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
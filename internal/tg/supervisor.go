@@ -0,0 +1,177 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/tg"
+)
+
+// State is the connection state of a Client.
+type State string
+
+const (
+	// StateConnecting means the client is (re)connecting to Telegram.
+	StateConnecting State = "connecting"
+	// StateAuthorizing means the client is connected but waiting for the
+	// login flow (phone number and code on the terminal).
+	StateAuthorizing State = "authorizing"
+	// StateReady means API calls can be served.
+	StateReady State = "ready"
+	// StateDegraded means the connection failed and a reconnect is scheduled.
+	StateDegraded State = "degraded"
+)
+
+// Status describes the connection state of a Client.
+type Status struct {
+	State State     `json:"state"`
+	Since time.Time `json:"since"`
+	// Error is the last connection error while degraded.
+	Error string `json:"error,omitempty"`
+	// Retries counts the reconnects since the client was last ready.
+	Retries int `json:"retries,omitempty"`
+}
+
+// Reconnect backoff bounds. The delay doubles after every failed attempt
+// and is reset once the client has been ready for backoffResetAfter.
+const (
+	backoffInitial    = time.Second
+	backoffMax        = 5 * time.Minute
+	backoffResetAfter = time.Minute
+)
+
+// Status returns the current connection state.
+func (c *Client) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Ready reports whether API calls can be served.
+func (c *Client) Ready() bool {
+	return c.Status().State == StateReady
+}
+
+// CurrentUser returns the logged-in user, or nil before login.
+func (c *Client) CurrentUser() *tg.User {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.user
+}
+
+func (c *Client) setState(state State, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.status.State != state {
+		c.status.Since = time.Now()
+	}
+	c.status.State = state
+	if state == StateReady {
+		c.status.Retries = 0
+	}
+	c.status.Error = ""
+	if err != nil {
+		c.status.Error = err.Error()
+	}
+}
+
+// setConnection publishes the gotd client of the current connection, and
+// its API once logged in (api and user are nil until then).
+func (c *Client) setConnection(client *telegram.Client, api *tg.Client, user *tg.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.client = client
+	c.api = api
+	c.user = user
+}
+
+// rpc returns the API of the current connection, or ErrNotReady while
+// (re)connecting. Handlers may run concurrently with a reconnect, so the
+// API must always be fetched through here.
+func (c *Client) rpc() (*tg.Client, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.api == nil {
+		return nil, ErrNotReady
+	}
+	return c.api, nil
+}
+
+// errReconnect is returned by StartAndListen when the connection was ended
+// by Reconnect.
+var errReconnect = errors.New("reconnect requested")
+
+// Reconnect ends the current connection, e.g. to pick up an imported
+// session. Supervise connects again right away.
+func (c *Client) Reconnect() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.disconnect != nil {
+		c.disconnect()
+	}
+}
+
+// Supervise keeps the client connected until ctx is done. Connection
+// errors put it in StateDegraded and it reconnects with exponential
+// backoff. It only returns early, with ErrNotLoggedIn, if the account has
+// no session and the client is non-interactive.
+func (c *Client) Supervise(ctx context.Context) error {
+	delay := backoffInitial
+	retries := 0
+
+	for {
+		var readyAt time.Time
+		err := c.StartAndListen(ctx, func(ctx context.Context) error {
+			readyAt = time.Now()
+			<-ctx.Done()
+			return nil
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, ErrNotLoggedIn) {
+			c.setState(StateDegraded, err)
+			return err
+		}
+		if errors.Is(err, errReconnect) {
+			c.log.Info("Reconnecting to Telegram")
+			delay = backoffInitial
+			retries = 0
+			continue
+		}
+		if err == nil {
+			err = errors.New("connection closed")
+		}
+
+		// A connection that stayed up for a while starts over with short delays
+		if !readyAt.IsZero() && time.Since(readyAt) > backoffResetAfter {
+			delay = backoffInitial
+			retries = 0
+		}
+		retries++
+
+		// Up to 20% jitter so several accounts do not reconnect in lockstep
+		wait := delay + time.Duration(rand.Int64N(int64(delay)/5+1))
+		c.log.Warn("Telegram connection lost, reconnecting", "err", err, "in", wait.Round(time.Second), "attempt", retries)
+
+		c.setState(StateDegraded, err)
+		c.mu.Lock()
+		c.status.Retries = retries
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+
+		delay *= 2
+		if delay > backoffMax {
+			delay = backoffMax
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gotd/td/bin"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+)
+
+// DefaultTakeoutWait is how long ExportMessages waits by default for the
+// user to allow a takeout session.
+const DefaultTakeoutWait = 5 * time.Minute
+
+// maxTakeoutFileSize is the largest file a takeout session may download,
+// the largest file Telegram accepts at all.
+const maxTakeoutFileSize = 4000 << 20
+
+// takeoutPollInterval is how often the takeout session is requested again
+// while waiting for the user to allow it. A variable for the tests.
+var takeoutPollInterval = 10 * time.Second
+
+// ExportOptions configure ExportMessages.
+type ExportOptions struct {
+	// Takeout runs the export in a takeout session, which Telegram rate
+	// limits far less than regular requests. If Telegram refuses one, the
+	// export runs without it.
+	Takeout bool
+	// TakeoutWait bounds how long to wait for the user to allow the takeout
+	// session in another Telegram app (default DefaultTakeoutWait).
+	TakeoutWait time.Duration
+
+	// Media, when set, is called for every photo and document, newest
+	// first, with a function streaming the file into a writer, so large
+	// files never sit in memory. Media that fails to download is logged and
+	// skipped rather than failing the export; an error writing it (e.g. a
+	// full disk) or returned by Media itself ends the export.
+	Media func(msgID int, contentType string, download func(w io.Writer) error) error
+}
+
+// ExportMessages returns the whole Saved Messages history, newest first,
+// with albums grouped like GetSavedMessages.
+func (c *Client) ExportMessages(ctx context.Context, opts ExportOptions) (_ []SavedMessage, rerr error) {
+	api, err := c.rpc()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Takeout {
+		takeout, err := c.initTakeout(ctx, api, opts)
+		switch {
+		case err == nil:
+			api = takeout
+			defer func() { c.finishTakeout(ctx, takeout, rerr == nil) }()
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		default:
+			c.log.Warn("Takeout session refused, exporting without it", "err", err)
+		}
+	}
+
+	// Albums may straddle history pages, so group once everything is in
+	var messages []tg.MessageClass
+	err = walkHistoryWith(ctx, api, func(m *tg.Message, _ *historyPeers) error {
+		messages = append(messages, m)
+		if opts.Media == nil || m.Media == nil {
+			return nil
+		}
+		return c.exportMedia(ctx, api, m, opts.Media)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return groupMessages(messages), nil
+}
+
+// exportMedia hands the photo or document of m to fn for downloading.
+func (c *Client) exportMedia(ctx context.Context, api *tg.Client, m *tg.Message, fn func(msgID int, contentType string, download func(w io.Writer) error) error) error {
+	switch m.Media.(type) {
+	case *tg.MessageMediaPhoto, *tg.MessageMediaDocument:
+	default:
+		return nil
+	}
+
+	location, contentType, err := c.locationOf(m)
+	if err != nil {
+		c.log.Warn("Skipping media of exported message", "id", m.ID, "err", err)
+		return nil
+	}
+
+	var downloadErr, writeErr error
+	err = fn(m.ID, contentType, func(w io.Writer) error {
+		cw := &countingWriter{w: w}
+		downloadErr = c.streamMedia(ctx, api, m.ID, mediaSource{location: location, contentType: contentType}, cw)
+		writeErr = cw.err
+		return downloadErr
+	})
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case downloadErr == nil || writeErr != nil:
+		return err
+	}
+	c.log.Warn("Skipping media of exported message", "id", m.ID, "err", err)
+	return nil
+}
+
+// initTakeout opens a takeout session and returns an API client making
+// every request in it.
+//
+// Telegram only grants a takeout session once the user allows it from
+// another app, answering TAKEOUT_INIT_DELAY_X meanwhile (X being when it
+// would be granted anyway, typically a day). The request is repeated until
+// the user allows it or opts.TakeoutWait runs out.
+func (c *Client) initTakeout(ctx context.Context, api *tg.Client, opts ExportOptions) (*tg.Client, error) {
+	wait := opts.TakeoutWait
+	if wait <= 0 {
+		wait = DefaultTakeoutWait
+	}
+	deadline := time.Now().Add(wait)
+
+	req := &tg.AccountInitTakeoutSessionRequest{
+		// Saved Messages is the chat with ourselves
+		MessageUsers: true,
+	}
+	if opts.Media != nil {
+		req.Files = true
+		req.SetFileMaxSize(maxTakeoutFileSize)
+	}
+
+	for asked := false; ; asked = true {
+		takeout, err := api.AccountInitTakeoutSession(ctx, req)
+		if err == nil {
+			c.log.Info("Takeout session started", "takeout_id", takeout.ID)
+			return tg.NewClient(takeoutInvoker{id: takeout.ID, next: api.Invoker()}), nil
+		}
+
+		rpcErr, ok := tgerr.AsType(err, tg.ErrTakeoutInitDelay)
+		if !ok {
+			return nil, fmt.Errorf("failed to init takeout session: %w", err)
+		}
+		if !asked {
+			c.log.Info("Allow the data export in another Telegram app to continue",
+				"wait", wait, "telegram_delay", time.Duration(rpcErr.Argument)*time.Second)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, errors.New("takeout session was not allowed in time")
+		}
+		timer := time.NewTimer(min(takeoutPollInterval, remaining))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// finishTakeout ends the takeout session, telling Telegram whether the
+// export succeeded. It runs even when ctx is done, so the session is not
+// left open.
+func (c *Client) finishTakeout(ctx context.Context, takeout *tg.Client, success bool) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+
+	if _, err := takeout.AccountFinishTakeoutSession(ctx, &tg.AccountFinishTakeoutSessionRequest{Success: success}); err != nil {
+		c.log.Warn("Failed to finish takeout session", "err", err)
+		return
+	}
+	c.log.Info("Takeout session finished", "success", success)
+}
+
+// takeoutInvoker wraps every request in invokeWithTakeout.
+type takeoutInvoker struct {
+	id   int64
+	next tg.Invoker
+}
+
+// Invoke implements tg.Invoker.
+func (t takeoutInvoker) Invoke(ctx context.Context, input bin.Encoder, output bin.Decoder) error {
+	return t.next.Invoke(ctx, &takeoutRequest{tg.InvokeWithTakeoutRequest{
+		TakeoutID: t.id,
+		Query:     encodeOnly{input},
+	}}, output)
+}
+
+// takeoutRequest is invokeWithTakeout named after the wrapped method, so
+// the metrics count the method rather than the wrapper.
+type takeoutRequest struct {
+	tg.InvokeWithTakeoutRequest
+}
+
+func (r *takeoutRequest) TypeName() string {
+	if named, ok := r.Query.(encodeOnly).Encoder.(interface{ TypeName() string }); ok {
+		return named.TypeName()
+	}
+	return r.InvokeWithTakeoutRequest.TypeName()
+}
+
+// encodeOnly makes a request usable as the query of an invoke wrapper,
+// which are only ever encoded.
+type encodeOnly struct {
+	bin.Encoder
+}
+
+func (encodeOnly) Decode(*bin.Buffer) error {
+	return errors.New("not implemented")
+}
@@ -0,0 +1,92 @@
+package tg
+
+import (
+	"bytes"
+	"io"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestExportMessagesInTakeout(t *testing.T) {
+	takeoutPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { takeoutPollInterval = 10 * time.Second })
+
+	s := newTestServer(t)
+	// The user allows the takeout after the client asked twice
+	s.takeoutDelays = 2
+	photo := bytes.Repeat([]byte("p"), 1000)
+	doc := bytes.Repeat([]byte("d"), 3000)
+	s.addMessages(textMessage(4, "note"))
+	s.addMessages(inAlbum(9, photoMessage(3, 30, photoSizes("x")...), documentMessage(2, 20, "application/pdf", int64(len(doc))))...)
+	s.addMessages(textMessage(1, "first"))
+	s.addFile(30, photo)
+	s.addFile(20, doc)
+	c := startClient(t, s, Options{})
+
+	media := map[int][]byte{}
+	messages, err := c.ExportMessages(testContext(t), ExportOptions{
+		Takeout: true,
+		Media: func(msgID int, contentType string, download func(w io.Writer) error) error {
+			var data bytes.Buffer
+			if err := download(&data); err != nil {
+				return err
+			}
+			media[msgID] = data.Bytes()
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExportMessages failed: %v", err)
+	}
+
+	var ids [][]int
+	for _, m := range messages {
+		ids = append(ids, m.IDs)
+	}
+	if want := [][]int{{4}, {3, 2}, {1}}; !slices.EqualFunc(ids, want, slices.Equal) {
+		t.Errorf("exported %v, want %v", ids, want)
+	}
+	if !bytes.Equal(media[3], photo) || !bytes.Equal(media[2], doc) || len(media) != 2 {
+		t.Errorf("exported media of messages %v", slices.Sorted(maps.Keys(media)))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.takeoutDelays != 0 {
+		t.Errorf("takeout session granted with %d delays left", s.takeoutDelays)
+	}
+	if s.takeoutRequests[tg.MessagesGetHistoryRequestTypeID] == 0 || s.takeoutRequests[tg.UploadGetFileRequestTypeID] == 0 {
+		t.Errorf("requests in the takeout session: %v", s.takeoutRequests)
+	}
+	if s.takeoutRequests[tg.AccountFinishTakeoutSessionRequestTypeID] != 1 || !slices.Equal(s.takeoutFinished, []bool{true}) {
+		t.Errorf("takeout sessions finished: %v, want one successful", s.takeoutFinished)
+	}
+}
+
+func TestExportMessagesTakeoutRefused(t *testing.T) {
+	takeoutPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { takeoutPollInterval = 10 * time.Second })
+
+	s := newTestServer(t)
+	s.takeoutDelays = -1
+	s.addMessages(textMessage(2, "second"), textMessage(1, "first"))
+	c := startClient(t, s, Options{})
+
+	messages, err := c.ExportMessages(testContext(t), ExportOptions{Takeout: true, TakeoutWait: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("ExportMessages failed: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Errorf("exported %d messages, want 2", len(messages))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.takeoutRequests) != 0 || len(s.takeoutFinished) != 0 {
+		t.Errorf("used a refused takeout session: requests %v, finished %v", s.takeoutRequests, s.takeoutFinished)
+	}
+}
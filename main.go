@@ -2,9 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
+	"telegram-manager/internal/audit"
+	"telegram-manager/internal/cli"
+	"telegram-manager/internal/config"
 	"telegram-manager/internal/server"
 	"telegram-manager/internal/tg"
 )
@@ -12,66 +21,152 @@ import (
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 
-	// Initialize Telegram Client
-	tgClient, err := tg.NewClient()
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	sessionSecret, err := cfg.SessionSecret()
 	if err != nil {
-		log.Fatalf("Failed to create Telegram client: %v. Make sure TG_APP_ID and TG_APP_HASH are set.", err)
+		log.Fatalf("Invalid configuration: %v", err)
 	}
 
-	// The client needs to connect and authenticate before we can really use it.
-	// We'll run the client in a blocking manner as intended by gotd, but since we also need an HTTP server,
-	// we will start the HTTP server inside the `onReady` callback or in a goroutine.
-	// gotd's Run() blocks until connection is closed.
+	// Validated by config.Load
+	tgProxy, _ := cfg.TelegramProxy()
 
-	// Better approach: Start HTTP server in a goroutine, but it needs a ready client.
-	// Or: Use Client.StartAndListen which blocks. Inside the callback (when ready), we start the HTTP server?
-	// No, `http.ListenAndServe` blocks too.
+	setupLogging(cfg.Log)
 
-	// We will start the HTTP server in a goroutine, but it might fail requests until TG is ready.
-	// Or we wait for TG to be ready.
+	var auditLog *audit.Log
+	if cfg.Log.AuditFile != "" {
+		if auditLog, err = audit.Open(cfg.Log.AuditFile); err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer auditLog.Close()
+	}
+
+	tgOpts := tg.Options{
+		AppID:           cfg.Telegram.AppID,
+		AppHash:         cfg.Telegram.AppHash,
+		SessionDir:      cfg.Session.Dir,
+		SessionSecret:   sessionSecret,
+		DefaultPageSize: cfg.Messages.DefaultPageSize,
+		MaxPageSize:     cfg.Messages.MaxPageSize,
+		MediaCacheBytes: cfg.Cache.MediaMaxBytes,
+		MediaCacheTTL:   cfg.Cache.MediaTTL,
+		MediaWorkers:    cfg.Telegram.MediaWorkers,
+		Proxy:           tgProxy,
+	}
+
+	// Anything but "serve" runs a single CLI command against one account
+	if len(args) > 0 && args[0] != "serve" {
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+		defer cancel()
+
+		if err := cli.Run(ctx, args, cfg.Telegram.Accounts, tgOpts, auditLog); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			cancel()
+			auditLog.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Initialize Telegram Clients, one per account. Each one gets its own
+	// session file and Telegram connection.
+	var clients []*tg.Client
+	for _, name := range cfg.Telegram.Accounts {
+		tgClient, err := tg.NewClient(name, tgOpts)
+		if err != nil {
+			slog.Error("Failed to create Telegram client", "account", name, "err", err)
+			os.Exit(1)
+		}
+		clients = append(clients, tgClient)
+	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 	defer cancel()
 
-	err = tgClient.StartAndListen(ctx, func(ctx context.Context) error {
-		// This callback is called when Auth is successful and client is ready.
+	// Each account is kept connected by its own supervisor, which reconnects
+	// with backoff when the connection drops. The HTTP server starts right
+	// away; requests to accounts that are not ready yet fail with 503 and
+	// /readyz reports when everything is up.
+	clientErr := make(chan error, len(clients))
 
-		srv := server.NewServer(tgClient)
-		port := os.Getenv("PORT")
-		if port == "" {
-			port = "8080"
+	var wg sync.WaitGroup
+	for _, c := range clients {
+		wg.Add(1)
+		go func(c *tg.Client) {
+			defer wg.Done()
+			if err := c.Supervise(ctx); err != nil {
+				slog.Error("Telegram client stopped", "account", c.Account, "err", err)
+				clientErr <- err
+			}
+		}(c)
+	}
+
+	port := strconv.Itoa(cfg.Server.Port)
+	redirectPort := ""
+	if cfg.Server.TLS.RedirectPort != 0 {
+		redirectPort = strconv.Itoa(cfg.Server.TLS.RedirectPort)
+	}
+
+	srv := server.NewServer(clients, server.Options{
+		Host:          cfg.Server.Host,
+		Password:      cfg.Server.Password,
+		StaticDir:     cfg.Server.StaticDir,
+		MediaMaxAge:   cfg.Cache.MediaTTL,
+		TLSCertFile:   cfg.Server.TLS.CertFile,
+		TLSKeyFile:    cfg.Server.TLS.KeyFile,
+		TLSSelfSigned: cfg.Server.TLS.SelfSigned,
+		TLSDir:        cfg.Server.TLS.Dir,
+		RedirectPort:  redirectPort,
+		Audit:         auditLog,
+	})
+
+	// A client that cannot recover takes the whole process down
+	clientFailed := make(chan struct{})
+	go func() {
+		select {
+		case <-clientErr:
+			close(clientFailed)
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
 
-		// Start HTTP Server blocking (it will listen on ctx.Done())
-		// Sinc we are inside StartAndListen, blocking here would block the TG client loop if StartAndListen expects prompt return?
-		// StartAndListen says: "It executes the 'onReady' callback when the client is authenticated and ready to query."
-		// If StartAndListen logic (in client.go) loops `client.Run` which executes callback...
-		// In gotd, `client.Run` blocks until the callback returns?
-		// No, `client.Run` runs the callback once authenticated and keeps connection open *while* callback is running?
-		// Let's check `client.go`.
-		// `err := client.Run(ctx, func(ctx) error { ... return onReady(ctx) })`
-		// So `client.Run` waits for `onReady` to return?
-		// If `onReady` returns `nil`, `client.Run` returns `nil` (and closes connection)?
-		// Yes, typically `client.Run` connects, auths, runs callback, then disconnects.
-		// So we MUST block in `onReady` if we want to keep using the client!
-
-		// The previous implementation had `<-ctx.Done()` at the end of callback.
-		// So my new `srv.Start(ctx, port)` which blocks until ctx.Done is perfect.
-
-		if err := srv.Start(ctx, port); err != nil {
-			// If server error (not shutdown), we log it
-			if err != context.Canceled {
-				log.Printf("HTTP Server stopped with error: %v", err)
-			}
+	// Start HTTP Server blocking (it will listen on ctx.Done())
+	if err := srv.Start(ctx, port); err != nil {
+		// If server error (not shutdown), we log it
+		if err != context.Canceled {
+			slog.Error("HTTP server stopped", "err", err)
 		}
+	}
 
-		// Ensure we respect context cancellation if Start returns early for some other reason
-		// But Start blocks on ctx.Done.
+	cancel()
+	wg.Wait()
 
-		return nil
-	})
+	select {
+	case <-clientFailed:
+		auditLog.Close()
+		os.Exit(1)
+	default:
+	}
+}
 
-	if err != nil {
-		log.Fatalf("Telegram Client Error: %v", err)
+// setupLogging makes slog, and the standard log package through it, write
+// to stderr at the configured level and format. stdout is left to CLI output.
+func setupLogging(cfg config.LogConfig) {
+	var level slog.Level
+	// Validated by config.Load
+	level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
 	}
+	slog.SetDefault(slog.New(handler))
 }
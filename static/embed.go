@@ -0,0 +1,10 @@
+// Package static embeds the web UI into the binary.
+package static
+
+import "embed"
+
+// FS holds the web UI files. The screenshot is only used by the README and
+// is left out to keep the binary small.
+//
+//go:embed *.html *.js *.css
+var FS embed.FS